@@ -0,0 +1,189 @@
+package main
+
+import "context"
+import "encoding/csv"
+import "fmt"
+import "os"
+import "os/exec"
+import "path/filepath"
+import "sort"
+import "strings"
+import "time"
+import "flag"
+import "runtime"
+
+import "discrete-optimization-001/1knapsack/knapsack"
+
+const (
+    // the run is killed if it doesn't stop this long after the time limit
+    KILL_GRACE_PERIOD = 10 * time.Second
+)
+
+// result of one alg on one instance
+type Run struct {
+    instance string
+    alg string
+    status string // ok, timeout, killed, failed
+    value string
+    optimal string
+    time time.Duration
+    peakMemory int64 // max resident set size, KB (-1 -- unknown)
+}
+
+// solve the instance in this process and print "value optimal", the time
+// limit stops both B&B (with the incumbent) and DP (with no solution, so
+// "timeout" is printed)
+func runAlg(filename string, alg string, opts knapsack.Options) int {
+    inst, err := knapsack.ReadFile(filename)
+    if err != nil {
+        fmt.Fprintln(os.Stderr, "Cannot open file:", filename, err)
+        return 2
+    }
+    ctx := context.Background()
+    if opts.TimeLimit > 0 {
+        var cancel context.CancelFunc
+        ctx, cancel = context.WithTimeout(ctx, opts.TimeLimit)
+        defer cancel()
+    }
+    r, err := knapsack.Solve(ctx, inst, alg, opts)
+    if err == context.DeadlineExceeded {
+        fmt.Println("timeout")
+        return 0
+    }
+    if err != nil {
+        fmt.Fprintln(os.Stderr, "Cannot solve:", err)
+        return 1
+    }
+    optimal := 0
+    if r.Optimal {
+        optimal = 1
+    }
+    fmt.Println(r.Value, optimal)
+    return 0
+}
+
+// every run is a separate process, so that its peak memory is measured
+// by the OS and does not depend on the previous runs; the run without the
+// time limit is never killed
+func benchmark(filename string, alg string, timeLimit time.Duration,
+               workers int) Run {
+    run := Run{instance: filepath.Base(filename), alg: alg, status: "failed"}
+    self, err := os.Executable()
+    if err != nil {
+        return run
+    }
+
+    ctx := context.Background()
+    if timeLimit > 0 {
+        var cancel context.CancelFunc
+        ctx, cancel = context.WithTimeout(ctx, timeLimit + KILL_GRACE_PERIOD)
+        defer cancel()
+    }
+    cmd := exec.CommandContext(ctx, self,
+                               "-time", fmt.Sprint(timeLimit.Seconds()),
+                               "-workers", fmt.Sprint(workers),
+                               "-run", filename, alg)
+    start := time.Now()
+    out, err := cmd.Output()
+    run.time = time.Since(start)
+    run.peakMemory = peakMemory(cmd.ProcessState)
+
+    fields := strings.Fields(string(out))
+    switch {
+    case ctx.Err() != nil:
+        run.status = "killed"
+    case err == nil && len(fields) == 1 && fields[0] == "timeout":
+        run.status = "timeout"
+    case err == nil && len(fields) == 2:
+        run.status = "ok"
+        run.value, run.optimal = fields[0], fields[1]
+    }
+    return run
+}
+
+func header() []string {
+    return []string{"instance", "alg", "status", "value", "optimal", "time_s", "peak_rss_mb"}
+}
+
+func (r Run) row() []string {
+    return []string{r.instance, r.alg, r.status, r.value, r.optimal,
+                    fmt.Sprintf("%.3f", r.time.Seconds()),
+                    r.peakMemoryMB()}
+}
+
+// empty if the platform doesn't report the peak memory
+func (r Run) peakMemoryMB() string {
+    if r.peakMemory < 0 {
+        return ""
+    }
+    return fmt.Sprintf("%.1f", float64(r.peakMemory) / 1024)
+}
+
+func printCSV(runs []Run) {
+    w := csv.NewWriter(os.Stdout)
+    w.Write(header())
+    for _, r := range runs {
+        w.Write(r.row())
+    }
+    w.Flush()
+}
+
+func printMarkdown(runs []Run) {
+    h := header()
+    fmt.Println("| " + strings.Join(h, " | ") + " |")
+    fmt.Println("|" + strings.Repeat(" --- |", len(h)))
+    for _, r := range runs {
+        fmt.Println("| " + strings.Join(r.row(), " | ") + " |")
+    }
+}
+
+// benchmark [flags] [instance...]
+// runs each alg on the instances (all files of the data dir if none is
+// given) and prints the table of results
+func main() {
+    dataDir := flag.String("data", "data", "directory with the instances")
+    algs := flag.String("algs", "dp,pareto,bnb,dfs,pbnb,core,heuristic",
+                        "comma separated algs to run")
+    timeLimit := flag.Float64("time", 10, "time limit of each run, seconds (0 -- no limit)")
+    workers := flag.Int("workers", runtime.NumCPU(), "number of parallel workers")
+    format := flag.String("format", "markdown", "output format: markdown or csv")
+    run := flag.String("run", "", "solve this instance with the alg given as the argument "+
+                                  "and print the result (used for the benchmark runs)")
+    flag.Parse()
+
+    limit := time.Duration(*timeLimit * float64(time.Second))
+    if *run != "" {
+        opts := knapsack.Options{TimeLimit: limit, Workers: *workers, Seed: 1}
+        os.Exit(runAlg(*run, flag.Arg(0), opts))
+    }
+
+    files := flag.Args()
+    if len(files) == 0 {
+        entries, err := os.ReadDir(*dataDir)
+        if err != nil {
+            fmt.Println("Cannot read directory:", *dataDir, err)
+            os.Exit(2)
+        }
+        for _, e := range entries {
+            if !e.IsDir() {
+                files = append(files, filepath.Join(*dataDir, e.Name()))
+            }
+        }
+        sort.Strings(files)
+    }
+
+    runs := make([]Run, 0)
+    for _, filename := range files {
+        for _, alg := range strings.Split(*algs, ",") {
+            r := benchmark(filename, alg, limit, *workers)
+            fmt.Fprintln(os.Stderr, strings.Join(r.row(), " "))
+            runs = append(runs, r)
+        }
+    }
+
+    if *format == "csv" {
+        printCSV(runs)
+    } else {
+        printMarkdown(runs)
+    }
+}
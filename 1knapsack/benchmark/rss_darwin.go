@@ -0,0 +1,18 @@
+//go:build darwin
+
+package main
+
+import "os"
+import "syscall"
+
+// max resident set size of the finished process, KB (-1 -- unknown)
+func peakMemory(state *os.ProcessState) int64 {
+    if state == nil {
+        return -1
+    }
+    if usage, ok := state.SysUsage().(*syscall.Rusage); ok {
+        // getrusage reports bytes on macOS
+        return int64(usage.Maxrss) / 1024
+    }
+    return -1
+}
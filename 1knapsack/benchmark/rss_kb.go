@@ -0,0 +1,18 @@
+//go:build linux || freebsd || netbsd || openbsd || dragonfly
+
+package main
+
+import "os"
+import "syscall"
+
+// max resident set size of the finished process, KB (-1 -- unknown)
+func peakMemory(state *os.ProcessState) int64 {
+    if state == nil {
+        return -1
+    }
+    if usage, ok := state.SysUsage().(*syscall.Rusage); ok {
+        // getrusage reports KB on Linux and BSD
+        return int64(usage.Maxrss)
+    }
+    return -1
+}
@@ -0,0 +1,10 @@
+//go:build !(linux || freebsd || netbsd || openbsd || dragonfly || darwin)
+
+package main
+
+import "os"
+
+// the peak memory is not reported on this platform
+func peakMemory(state *os.ProcessState) int64 {
+    return -1
+}
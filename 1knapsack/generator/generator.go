@@ -0,0 +1,40 @@
+package main
+
+import "fmt"
+import "os"
+import "flag"
+
+import "discrete-optimization-001/1knapsack/knapsack"
+
+// generator [flags] [output]
+// writes the instance of the Pisinger class in the plain format to output
+// file (stdout if it is not given)
+func main() {
+    class := flag.String("class", knapsack.CLASS_UNCORRELATED,
+                         "instance class: uncorrelated, weakly, strongly, inverse or subsetsum")
+    n := flag.Int("n", 100, "number of items")
+    R := flag.Int("range", 1000, "weights (and values) are in 1..range")
+    capacity := flag.Float64("capacity", 0.5, "capacity as the fraction of the total weight")
+    seed := flag.Int64("seed", 1, "random seed")
+    flag.Parse()
+
+    inst, err := knapsack.Generate(*class, *n, int32(*R), *capacity, *seed)
+    if err != nil {
+        fmt.Println("Cannot generate instance:", err)
+        os.Exit(1)
+    }
+
+    file := os.Stdout
+    if flag.NArg() > 0 {
+        file, err = os.Create(flag.Arg(0))
+        if err != nil {
+            fmt.Println("Cannot create file:", flag.Arg(0), err)
+            os.Exit(2)
+        }
+        defer file.Close()
+    }
+    if err := knapsack.Write(file, inst); err != nil {
+        fmt.Println("Cannot write instance:", err)
+        os.Exit(2)
+    }
+}
@@ -0,0 +1,3 @@
+module discrete-optimization-001/1knapsack
+
+go 1.19
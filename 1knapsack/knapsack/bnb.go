@@ -0,0 +1,840 @@
+package knapsack
+
+import "sort"
+import "time"
+import "sync"
+import "math"
+import "math/rand"
+import "container/heap"
+
+type node struct {
+    index int32   // index in the input data
+    value int32
+    weight int32
+    bound int64   // this is used as priority
+    selected byte
+    sel []byte
+}
+
+// Priority queue -------------------------------------------------------------
+
+type itemList []node
+
+func (self itemList) Len() int { return len(self) }
+func (self itemList) Less(i, j int) bool { return self[i].bound < self[j].bound }
+func (self itemList) Swap(i, j int) { self[i], self[j] = self[j], self[i] }
+func (self *itemList) Push(x interface{}) { *self = append(*self, x.(node)) }
+func (self *itemList) Pop() (popped interface{}) {
+    popped = (*self)[len(*self)-1]
+    *self = (*self)[:len(*self)-1]
+    return
+}
+
+// Sorting --------------------------------------------------------------------
+
+type byValuePerWeight itemList
+func (self byValuePerWeight) Len() int { return len(self) }
+func (self byValuePerWeight) Less(i, j int) bool {
+    // compare v[i] / w[i] > v[j] / w[j] exactly, floating point division
+    // may misorder items with close ratios and break the bound
+    a := int64(self[i].value) * int64(self[j].weight)
+    b := int64(self[j].value) * int64(self[i].weight)
+    return a > b
+}
+func (self byValuePerWeight) Swap(i, j int) { self[i], self[j] = self[j], self[i] }
+
+type byIndex itemList
+func (self byIndex) Len() int { return len(self) }
+func (self byIndex) Less(i, j int) bool { return self[i].index < self[j].index }
+func (self byIndex) Swap(i, j int) { self[i], self[j] = self[j], self[i] }
+
+// Branch and Bound -----------------------------------------------------------
+
+// fractional (LP relaxation, Dantzig) bound of the node, items must be
+// sorted by value per weight; since all values are integers the fractional
+// part of the last item is rounded down, it can't be used anyway
+func (self *node) estimateDantzig(K int32, N int32, items itemList) int64 {
+    var j, k int32
+    var totweight int64
+    var result int64
+
+    if self.weight > K {
+        return 0
+    }
+
+    result = int64(self.value)
+    totweight = int64(self.weight)
+    j = self.index + 1
+
+    for j < N && totweight + int64(items[j].weight) <= int64(K) {
+        totweight += int64(items[j].weight)
+        result += int64(items[j].value)
+        j++
+    }
+
+    k = j
+    if k < N {
+        // int64 product can't overflow: both factors are int32
+        result += (int64(K) - totweight) * int64(items[k].value) / int64(items[k].weight)
+    }
+
+    return result
+}
+
+// Martello-Toth U2 bound of the node, never worse than Dantzig bound:
+// the critical item c (first one which does not fit) is either not taken,
+// then the rest of capacity is filled with fractions of item c+1, or taken,
+// then fractions of item c-1 are removed to make room for it
+func (self *node) estimate(K int32, N int32, items itemList) int64 {
+    if self.weight > K {
+        return 0
+    }
+
+    V := int64(self.value)
+    r := int64(K) - int64(self.weight)
+    first := self.index + 1
+    j := first
+    for j < N && int64(items[j].weight) <= r {
+        r -= int64(items[j].weight)
+        V += int64(items[j].value)
+        j++
+    }
+    if j >= N {
+        return V
+    }
+
+    c := j
+    u0 := V
+    if c + 1 < N && items[c+1].weight > 0 {
+        u0 += r * int64(items[c+1].value) / int64(items[c+1].weight)
+    }
+    // item c-1 must be undecided to be removed
+    u1 := int64(-1)
+    if c - 1 >= first && items[c-1].weight > 0 {
+        removed := (int64(items[c].weight) - r) * int64(items[c-1].value)
+        // round removed value up, so that the bound is rounded down
+        removed = (removed + int64(items[c-1].weight) - 1) / int64(items[c-1].weight)
+        u1 = V + int64(items[c].value) - removed
+    }
+
+    if u1 > u0 {
+        return u1
+    }
+    return u0
+}
+
+// max bound of the nodes left in the queue (or incumbent value if they are
+// all worse)
+func (self itemList) maxBound(maxvalue int32) int64 {
+    result := int64(maxvalue)
+    for i := 0; i < len(self); i++ {
+        if self[i].bound > result {
+            result = self[i].bound
+        }
+    }
+    return result
+}
+
+func logProgress(opts Options, nodes int64, maxvalue int32, bound int64) {
+    if opts.Logger == nil {
+        return
+    }
+    gap := float64(0)
+    if bound > 0 {
+        gap = float64(bound - int64(maxvalue)) / float64(bound) * 100
+    }
+    opts.Logger.Printf("nodes %d incumbent %d bound %d gap %.4f%%\n",
+                       nodes, maxvalue, bound, gap)
+}
+
+// keeps track of expanded nodes and elapsed time to enforce Options limits
+type searchMonitor struct {
+    opts Options
+    nodes int64 // number of expanded nodes
+    start time.Time
+    lastLog time.Time
+}
+
+func newSearchMonitor(opts Options) *searchMonitor {
+    now := time.Now()
+    return &searchMonitor{opts, 0, now, now}
+}
+
+// returns true if the search must be stopped; bound is called only when
+// the progress is logged, since it may be expensive
+func (m *searchMonitor) stop(maxvalue int32, bound func() int64) bool {
+    if m.opts.MaxNodes > 0 && m.nodes >= m.opts.MaxNodes {
+        m.opts.logln("node limit reached:", m.nodes)
+        return true
+    }
+    // calling time.Now() for each node is too expensive
+    if m.nodes % 1024 == 0 {
+        return m.stopByTime(maxvalue, bound)
+    }
+    return false
+}
+
+// check time limit and cancellation, log progress if it is time to
+func (m *searchMonitor) stopByTime(maxvalue int32, bound func() int64) bool {
+    if m.opts.ctx != nil && m.opts.ctx.Err() != nil {
+        m.opts.logln("search canceled:", m.opts.ctx.Err())
+        return true
+    }
+    if m.opts.TimeLimit > 0 || m.opts.LogInterval > 0 {
+        now := time.Now()
+        if m.opts.TimeLimit > 0 && now.Sub(m.start) >= m.opts.TimeLimit {
+            m.opts.logln("time limit reached:", now.Sub(m.start))
+            return true
+        }
+        if m.opts.LogInterval > 0 && now.Sub(m.lastLog) >= m.opts.LogInterval {
+            logProgress(m.opts, m.nodes, maxvalue, bound())
+            m.lastLog = now
+        }
+    }
+    return false
+}
+
+func (m *searchMonitor) done(maxvalue int32, bound int64) {
+    if m.opts.LogInterval > 0 {
+        logProgress(m.opts, m.nodes, maxvalue, bound)
+    }
+}
+
+// search for the best solution with the branch and bound method
+// K -- knapsack capacity
+// items -- items sorted by value per weight
+// maxvalue -- best found value
+// bound -- proven upper bound of the optimal value
+// returns best selected items (in items order) and whether maxvalue is
+// proven to be optimal
+type branchAndBoundFunc func(K int32, items itemList, opts Options,
+                             maxvalue *int32, bound *int64) ([]byte, bool)
+
+// see
+// http://books.google.ru/books?id=QrvsNy9paOYC&pg=PA235&lpg=PA235&dq=knapsack+problem+branch+and+bound+C%2B%2B&source=bl&ots=e6ok2kODMN&sig=Yh5__d3iAFa5rEkaCoBJ2JAWybk&hl=en&sa=X&ei=k1EDULDrHIfKqgHqtYyxDA&redir_esc=y#v=onepage&q&f=true
+
+// best-first search; the search space is exhausted unless time or node
+// limit from opts is reached, in which case the incumbent is returned
+func knapsackBranchAndBound(K int32, items itemList, opts Options,
+                            maxvalue *int32, bound *int64) ([]byte, bool) {
+    return knapsackBranchAndBoundConflicts(K, items, nil, opts, maxvalue, bound)
+}
+
+// best-first search with conflicts: conflicts[i] -- items which can't be
+// taken together with item i (nil if there are no conflicts at all), the
+// child including the item in conflict with the selected one is not
+// generated
+func knapsackBranchAndBoundConflicts(K int32, items itemList, conflicts [][]int32,
+                                     opts Options,
+                                     maxvalue *int32, bound *int64) ([]byte, bool) {
+    var N int32 = int32(len(items))
+    var u, v node
+    //var x = make([]byte, N) // currently selected items
+    var bestset = make([]byte, N) // best selected items
+    pq := &itemList{}
+    monitor := newSearchMonitor(opts)
+
+    heap.Init(pq)
+    // the incoming value is a lower bound of the optimum, e.g. heuristic one,
+    // only better solutions are searched for
+    if *maxvalue < 0 {
+        *maxvalue = 0
+    }
+
+    // initialize root
+    u = node{0, 0, 0, 0, 0, make([]byte, N)}
+    // index = -1, start with fake root node
+    v = node{-1, 0, 0, 0, 0, make([]byte, N)}
+    v.bound = v.estimateConflicts(K, N, items, conflicts)
+    heap.Push(pq, v)
+
+    for pq.Len() != 0 {
+        if monitor.stop(*maxvalue, func() int64 { return pq.maxBound(*maxvalue) }) {
+            break
+        }
+
+        v = heap.Pop(pq).(node)
+        monitor.nodes++
+        // leaves have no children, they were already checked when created
+        if v.index+1 < N && v.bound > int64(*maxvalue) &&
+            !v.forbidden(v.index+1, conflicts) {
+            // make child that includes the item
+            u = node{v.index+1,
+                     v.value + items[v.index+1].value,
+                     v.weight + items[v.index+1].weight,
+                     0,
+                     0,
+                     make([]byte, N)}
+
+            copy(u.sel, v.sel)
+            u.sel[u.index] = 1
+
+            if u.weight <= K && u.value > *maxvalue {
+                *maxvalue = u.value
+                copy(bestset, u.sel)
+            }
+            u.bound = u.estimateConflicts(K, N, items, conflicts)
+            if u.bound > int64(*maxvalue) {
+                heap.Push(pq, u)
+            }
+        }
+        if v.index+1 < N && v.bound > int64(*maxvalue) {
+            // make child that does not include the item
+            u = node{v.index+1,
+                     v.value,
+                     v.weight,
+                     0,
+                     0,
+                     make([]byte, N)}
+            copy(u.sel, v.sel)
+            u.sel[u.index] = 0
+            u.bound = u.estimateConflicts(K, N, items, conflicts)
+
+            if u.bound > int64(*maxvalue) {
+                heap.Push(pq, u)
+            }
+        }
+    }
+
+    // if the queue is exhausted, every node was either expanded or pruned
+    // by a bound not better than maxvalue; otherwise the remaining nodes
+    // may still contain a better solution
+    *bound = pq.maxBound(*maxvalue)
+    monitor.done(*maxvalue, *bound)
+    return bestset, *bound <= int64(*maxvalue)
+}
+
+// state of the depth-first search; only one selection vector is shared by
+// all the nodes, so the memory used does not depend on the number of nodes
+type dfsContext struct {
+    K int32
+    N int32
+    items itemList
+    x []byte         // currently selected items
+    bestset []byte   // best selected items
+    maxvalue int32
+    // pending[i] -- bound of the not yet visited "exclude item i" sibling
+    // on the current path (-1 if there is none)
+    pending []int64
+    monitor *searchMonitor
+    stopped bool
+    bound int64      // upper bound of the unvisited nodes after the stop
+}
+
+// max bound among the nodes not visited yet, node is the current one
+func (c *dfsContext) openBound(v *node) int64 {
+    result := int64(c.maxvalue)
+    if v.bound > result {
+        result = v.bound
+    }
+    for i := int32(0); i <= v.index; i++ {
+        if c.pending[i] > result {
+            result = c.pending[i]
+        }
+    }
+    return result
+}
+
+func (c *dfsContext) search(v *node) {
+    if c.monitor.stop(c.maxvalue, func() int64 { return c.openBound(v) }) {
+        c.stopped = true
+        c.bound = c.openBound(v)
+        return
+    }
+    c.monitor.nodes++
+
+    next := v.index + 1
+    if next >= c.N {
+        return
+    }
+
+    in := node{next,
+               v.value + c.items[next].value,
+               v.weight + c.items[next].weight,
+               0, 0, nil}
+    out := node{next, v.value, v.weight, 0, 0, nil}
+    out.bound = out.estimate(c.K, c.N, c.items)
+
+    // include the item first, it gives good incumbent earlier
+    if in.weight <= c.K {
+        c.pending[next] = out.bound
+        c.x[next] = 1
+        if in.value > c.maxvalue {
+            c.maxvalue = in.value
+            copy(c.bestset, c.x)
+        }
+        in.bound = in.estimate(c.K, c.N, c.items)
+        if in.bound > int64(c.maxvalue) {
+            c.search(&in)
+        }
+        // undo
+        c.x[next] = 0
+        c.pending[next] = -1
+        if c.stopped {
+            // the other child was not visited
+            if out.bound > c.bound {
+                c.bound = out.bound
+            }
+            return
+        }
+    }
+
+    if out.bound > int64(c.maxvalue) {
+        c.search(&out)
+    }
+}
+
+// depth-first search; the memory usage is O(N) in total, unlike
+// knapsackBranchAndBound which keeps a selection vector in every node
+func knapsackDepthFirst(K int32, items itemList, opts Options,
+                        maxvalue *int32, bound *int64) ([]byte, bool) {
+    var N int32 = int32(len(items))
+    // only solutions better than the incoming lower bound are searched for
+    var lower int32
+    if *maxvalue > 0 {
+        lower = *maxvalue
+    }
+    c := dfsContext{K, N, items,
+                    make([]byte, N), make([]byte, N),
+                    lower,
+                    make([]int64, N),
+                    newSearchMonitor(opts),
+                    false,
+                    0}
+    for i := range c.pending {
+        c.pending[i] = -1
+    }
+
+    // index = -1, start with fake root node
+    root := node{-1, 0, 0, 0, 0, nil}
+    root.bound = root.estimate(K, N, items)
+    c.search(&root)
+
+    *maxvalue = c.maxvalue
+    *bound = int64(c.maxvalue)
+    if c.stopped && c.bound > *bound {
+        *bound = c.bound
+    }
+    c.monitor.done(*maxvalue, *bound)
+    return c.bestset, *bound <= int64(*maxvalue)
+}
+
+// Heuristic ------------------------------------------------------------------
+
+const (
+    // half size of the window around the break item searched by 2-swaps
+    heuristicWindow = 16
+)
+
+// greedy solution: take items in the value per weight order while they fit
+func greedySolution(K int32, items itemList) (int32, []byte) {
+    x := make([]byte, len(items))
+    var value, weight int32
+    for i := 0; i < len(items); i++ {
+        if weight + items[i].weight <= K {
+            weight += items[i].weight
+            value += items[i].value
+            x[i] = 1
+        }
+    }
+    return value, x
+}
+
+// add items in the value per weight order while they fit
+func fillGreedy(K int32, items itemList, x []byte, value, weight *int32) {
+    for i := 0; i < len(items); i++ {
+        if x[i] == 0 && *weight + items[i].weight <= K {
+            *weight += items[i].weight
+            *value += items[i].value
+            x[i] = 1
+        }
+    }
+}
+
+// exchange of items: out are taken away and in are put into the knapsack
+type swapMove struct {
+    out []int
+    in []int
+    gain int32
+}
+
+// take the move if it fits and is better than the best one found so far
+func (m *swapMove) consider(K int32, items itemList, weight int32, out, in []int) {
+    var gain int32
+    for _, i := range out {
+        weight -= items[i].weight
+        gain -= items[i].value
+    }
+    for _, j := range in {
+        weight += items[j].weight
+        gain += items[j].value
+    }
+    if weight <= K && gain > m.gain {
+        m.out = append(m.out[:0], out...)
+        m.in = append(m.in[:0], in...)
+        m.gain = gain
+    }
+}
+
+// best 1-swap over all pairs of taken and not taken items, and best 2-swap
+// (two items for one, one for two or two for two) among items near the break
+// item, where the exchanges are most likely to improve the solution
+func bestSwap(K int32, items itemList, x []byte, weight int32, b int) swapMove {
+    var m swapMove
+    var taken, free []int
+    for i := 0; i < len(items); i++ {
+        if x[i] == 1 {
+            taken = append(taken, i)
+        } else {
+            free = append(free, i)
+        }
+    }
+    for _, i := range taken {
+        for _, j := range free {
+            if items[j].value > items[i].value {
+                m.consider(K, items, weight, []int{i}, []int{j})
+            }
+        }
+    }
+
+    from, to := b - heuristicWindow, b + heuristicWindow
+    if from < 0 {
+        from = 0
+    }
+    if to > len(items) {
+        to = len(items)
+    }
+    var takenNear, freeNear []int
+    for i := from; i < to; i++ {
+        if x[i] == 1 {
+            takenNear = append(takenNear, i)
+        } else {
+            freeNear = append(freeNear, i)
+        }
+    }
+    for a := 0; a < len(takenNear); a++ {
+        for c := 0; c < len(freeNear); c++ {
+            for d := c+1; d < len(freeNear); d++ {
+                m.consider(K, items, weight, takenNear[a:a+1], []int{freeNear[c], freeNear[d]})
+            }
+        }
+        for a2 := a+1; a2 < len(takenNear); a2++ {
+            out := []int{takenNear[a], takenNear[a2]}
+            for c := 0; c < len(freeNear); c++ {
+                m.consider(K, items, weight, out, freeNear[c:c+1])
+                for d := c+1; d < len(freeNear); d++ {
+                    m.consider(K, items, weight, out, []int{freeNear[c], freeNear[d]})
+                }
+            }
+        }
+    }
+    return m
+}
+
+// greedy solution improved by the local search: the best improving swap is
+// made and the freed capacity is filled greedily until there is no improving
+// swap, items should be sorted by value per weight
+func heuristicSolution(K int32, items itemList) (int32, []byte) {
+    value, x := greedySolution(K, items)
+    var weight int32
+    b := len(items) // break item of the greedy solution
+    for i := len(items)-1; i >= 0; i-- {
+        if x[i] == 1 {
+            weight += items[i].weight
+        } else {
+            b = i
+        }
+    }
+
+    for {
+        m := bestSwap(K, items, x, weight, b)
+        if m.gain <= 0 {
+            break
+        }
+        for _, i := range m.out {
+            x[i] = 0
+            weight -= items[i].weight
+        }
+        for _, j := range m.in {
+            x[j] = 1
+            weight += items[j].weight
+        }
+        value += m.gain
+        fillGreedy(K, items, x, &value, &weight)
+    }
+    return value, x
+}
+
+// heuristic as the B&B method: no search, the solution is optimal only if it
+// reaches the root bound
+func knapsackHeuristic(K int32, items itemList, opts Options,
+                       maxvalue *int32, bound *int64) ([]byte, bool) {
+    var x []byte
+    *maxvalue, x = heuristicSolution(K, items)
+    root := node{-1, 0, 0, 0, 0, nil}
+    *bound = root.estimate(K, int32(len(items)), items)
+    if *bound < int64(*maxvalue) {
+        *bound = int64(*maxvalue)
+    }
+    return x, *bound <= int64(*maxvalue)
+}
+
+// Reduction ------------------------------------------------------------------
+
+// wrap B&B method with the reduction step: item which is taken before the
+// break item (not taken after it) is fixed if the bound of solutions where
+// it is flipped is not better than the heuristic solution. Any solution
+// better than heuristic one has all the fixed items at their values, so only
+// the rest of items is searched, starting with the heuristic value as the
+// incumbent
+func withReduction(bnb branchAndBoundFunc) branchAndBoundFunc {
+    return func(K int32, items itemList, opts Options,
+                maxvalue *int32, bound *int64) ([]byte, bool) {
+        N := len(items)
+        p := newPrefixSums(items)
+        b := p.breakItem(K)
+        lower, heuristic := heuristicSolution(K, items)
+
+        fixed := make([]int8, N) // -1 -- free, 0 or 1 -- fixed value
+        free := make(itemList, 0)
+        var fixedValue, fixedWeight int32
+        var ones, zeros int
+        for i := 0; i < N; i++ {
+            fixed[i] = -1
+            if p.flippedBound(K, items, i, b) > int64(lower) {
+                free = append(free, items[i])
+            } else if i < b {
+                fixed[i] = 1
+                fixedValue += items[i].value
+                fixedWeight += items[i].weight
+                ones++
+            } else {
+                fixed[i] = 0
+                zeros++
+            }
+        }
+        opts.logln("reduction fixed", ones, "items to 1 and", zeros, "items to 0 of", N)
+
+        subValue := lower - fixedValue
+        var subBound int64
+        subset, _ := bnb(K - fixedWeight, free, opts, &subValue, &subBound)
+
+        bestset := make([]byte, N)
+        if fixedValue + subValue > lower {
+            *maxvalue = fixedValue + subValue
+            j := 0
+            for i := 0; i < N; i++ {
+                if fixed[i] == -1 {
+                    bestset[i] = subset[j]
+                    j++
+                } else {
+                    bestset[i] = byte(fixed[i])
+                }
+            }
+        } else {
+            *maxvalue = lower
+            copy(bestset, heuristic)
+        }
+
+        // solutions with some item flipped are not better than heuristic one
+        *bound = int64(fixedValue) + subBound
+        if *bound < int64(*maxvalue) {
+            *bound = int64(*maxvalue)
+        }
+        return bestset, *bound <= int64(*maxvalue)
+    }
+}
+
+// Parallel Branch and Bound --------------------------------------------------
+
+const (
+    // nodes taken from the queue for each worker in one round
+    parallelBatch = 64
+    // max depth of the dive from each taken node
+    parallelDiveDepth = 32
+)
+
+// output of one worker in one round
+type workerResult struct {
+    nodes int64      // expanded nodes
+    children []node  // nodes to be pushed to the queue
+    value int32      // best found value (-1 if not better than incumbent)
+    sel []byte       // best found selection
+}
+
+// expand the node and dive from it: continue with one of the children and
+// leave the other to the queue. Only the incumbent from the start of the
+// round is used for pruning, so the result does not depend on the timing
+// of the other workers. rng decides the child to dive into, the nodes left
+// when the worker has expanded quota nodes go back to the queue
+func diveFrom(K int32, items itemList, v node, incumbent int32, quota int64,
+              rng *rand.Rand, result *workerResult) {
+    N := int32(len(items))
+    best := incumbent
+    if result.value > best {
+        best = result.value
+    }
+
+    for depth := 0; ; depth++ {
+        if v.index + 1 >= N || v.bound <= int64(best) {
+            return
+        }
+        if depth == parallelDiveDepth || result.nodes >= quota {
+            result.children = append(result.children, v)
+            return
+        }
+        result.nodes++
+
+        next := v.index + 1
+        in := node{next,
+                   v.value + items[next].value,
+                   v.weight + items[next].weight,
+                   0, 0, make([]byte, N)}
+        copy(in.sel, v.sel)
+        in.sel[next] = 1
+        if in.weight <= K && in.value > best {
+            best = in.value
+            result.value = in.value
+            result.sel = in.sel
+        }
+        in.bound = in.estimate(K, N, items)
+
+        out := node{next, v.value, v.weight, 0, 0, make([]byte, N)}
+        copy(out.sel, v.sel)
+        out.bound = out.estimate(K, N, items)
+
+        // include the item first, mostly
+        first, second := in, out
+        if rng.Intn(4) == 0 {
+            first, second = out, in
+        }
+        if second.bound > int64(best) {
+            result.children = append(result.children, second)
+        }
+        v = first
+    }
+}
+
+// bulk-synchronous best-first search: in each round the nodes are taken
+// from the queue and divided between workers, which prune with their own
+// best values and the incumbent from the start of the round; between the
+// rounds the incumbent is updated and the new nodes are merged into the
+// queue in the order of workers, so that the search is deterministic for
+// the same seed and the number of workers.
+// The incumbent is deliberately not shared between workers during the round
+// (e.g. with an atomic): pruning with values found by other workers would
+// make the expanded nodes, and so the result under the node and time
+// limits, depend on the timing. The price is weaker pruning within a round,
+// which is at most parallelBatch dives per worker.
+// opts.MaxNodes is split between workers in each round, so the limit is
+// never exceeded
+func knapsackParallel(K int32, items itemList, opts Options,
+                      maxvalue *int32, bound *int64) ([]byte, bool) {
+    N := int32(len(items))
+    workers := opts.Workers
+    if workers < 1 {
+        workers = 1
+    }
+    var bestset = make([]byte, N)
+    var round int64
+    pq := &itemList{}
+    monitor := newSearchMonitor(opts)
+
+    heap.Init(pq)
+    // only solutions better than the incoming lower bound are searched for
+    if *maxvalue < 0 {
+        *maxvalue = 0
+    }
+
+    // index = -1, start with fake root node
+    root := node{-1, 0, 0, 0, 0, make([]byte, N)}
+    root.bound = root.estimate(K, N, items)
+    heap.Push(pq, root)
+
+    for pq.Len() != 0 {
+        if monitor.stop(*maxvalue, func() int64 { return pq.maxBound(*maxvalue) }) ||
+            monitor.stopByTime(*maxvalue, func() int64 { return pq.maxBound(*maxvalue) }) {
+            break
+        }
+
+        // take the batch and split it between workers
+        batch := make([]node, 0, workers * parallelBatch)
+        for pq.Len() != 0 && len(batch) < workers * parallelBatch {
+            v := heap.Pop(pq).(node)
+            if v.bound > int64(*maxvalue) {
+                batch = append(batch, v)
+            }
+        }
+
+        incumbent := *maxvalue
+        // node budget left for the round, the first workers get the remainder
+        left := int64(math.MaxInt64)
+        if opts.MaxNodes > 0 {
+            left = opts.MaxNodes - monitor.nodes
+        }
+        results := make([]workerResult, workers)
+        var wg sync.WaitGroup
+        for w := 0; w < workers; w++ {
+            wg.Add(1)
+            go func(w int) {
+                defer wg.Done()
+                results[w].value = -1
+                // each worker has its own generator, depending only on
+                // the seed, round and worker
+                rng := rand.New(rand.NewSource(opts.Seed + round * int64(workers) + int64(w)))
+                quota := left / int64(workers)
+                if int64(w) < left % int64(workers) {
+                    quota++
+                }
+                for j := w; j < len(batch); j += workers {
+                    diveFrom(K, items, batch[j], incumbent, quota, rng, &results[w])
+                }
+            }(w)
+        }
+        wg.Wait()
+        round++
+
+        // the first worker with the best value wins
+        for w := 0; w < workers; w++ {
+            if results[w].value > *maxvalue {
+                *maxvalue = results[w].value
+                copy(bestset, results[w].sel)
+            }
+        }
+        for w := 0; w < workers; w++ {
+            monitor.nodes += results[w].nodes
+            for _, u := range results[w].children {
+                if u.bound > int64(*maxvalue) {
+                    heap.Push(pq, u)
+                }
+            }
+        }
+    }
+
+    *bound = pq.maxBound(*maxvalue)
+    monitor.done(*maxvalue, *bound)
+    return bestset, *bound <= int64(*maxvalue)
+}
+
+// solve with the given branch and bound method, returns selected items in
+// the input order
+func branchAndBound(K int32, v []int32, w []int32, opts Options,
+                    bnb branchAndBoundFunc) (int32, []byte, bool, int64) {
+    N := len(v)
+    items := make([]node, N)
+    for i := 0; i < N; i++ {
+        items[i] = node{int32(i), v[i], w[i], -1, 0, nil}
+    }
+    sort.Sort(byValuePerWeight(items))
+
+    var maxvalue int32 = -1
+    var bound int64
+    bestset, optimal := bnb(K, items, opts, &maxvalue, &bound)
+
+    // restore indexes
+    x := make([]byte, N)
+    for i := 0; i < N; i++ {
+        x[items[i].index] = bestset[i]
+    }
+    return maxvalue, x, optimal, bound
+}
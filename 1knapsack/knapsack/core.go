@@ -0,0 +1,177 @@
+package knapsack
+
+import "context"
+import "sort"
+
+// Core algorithm -------------------------------------------------------------
+
+const (
+    // initial number of items taken around the break item into the core
+    coreInitialHalfSize = 8
+)
+
+// prefix sums of weights and values of items sorted by value per weight,
+// W[k], V[k] -- total weight and value of items[0..k-1]
+type prefixSums struct {
+    W []int64
+    V []int64
+}
+
+func newPrefixSums(items itemList) *prefixSums {
+    N := len(items)
+    p := &prefixSums{make([]int64, N+1), make([]int64, N+1)}
+    for i := 0; i < N; i++ {
+        p.W[i+1] = p.W[i] + int64(items[i].weight)
+        p.V[i+1] = p.V[i] + int64(items[i].value)
+    }
+    return p
+}
+
+// break item: the first item which does not fit into the knapsack when
+// items are taken greedily (N if all of them fit)
+func (p *prefixSums) breakItem(K int32) int {
+    return sort.Search(len(p.W) - 1, func(k int) bool { return p.W[k+1] > int64(K) })
+}
+
+// fractional bound with capacity C of all items except skip; O(log N)
+func (p *prefixSums) boundExcept(items itemList, C int64, skip int) int64 {
+    N := len(items)
+    if C < 0 {
+        return -1
+    }
+    // prefix sums without the skipped item are still monotonic
+    weight := func(k int) int64 {
+        if k > skip {
+            return p.W[k] - int64(items[skip].weight)
+        }
+        return p.W[k]
+    }
+    value := func(k int) int64 {
+        if k > skip {
+            return p.V[k] - int64(items[skip].value)
+        }
+        return p.V[k]
+    }
+    // number of whole items (k) which fit
+    k := sort.Search(N, func(k int) bool { return weight(k+1) > C })
+    result := value(k)
+    next := k
+    if next == skip {
+        next++
+    }
+    if next < N {
+        result += (C - weight(k)) * int64(items[next].value) / int64(items[next].weight)
+    }
+    return result
+}
+
+// upper bound of solutions where item i is flipped from its greedy
+// value: taken if it is after the break item b, not taken otherwise
+func (p *prefixSums) flippedBound(K int32, items itemList, i int, b int) int64 {
+    if i < b {
+        return p.boundExcept(items, int64(K), i)
+    }
+    return int64(items[i].value) +
+        p.boundExcept(items, int64(K) - int64(items[i].weight), i)
+}
+
+// Pisinger-style expanding core: items far from the break item are almost
+// always taken (before it) or not taken (after it) in the optimal
+// solution. Solve only a small core around the break item with sparse DP,
+// fixing the rest greedily, then use the bounds to prove that no item
+// outside the core can be flipped; otherwise add such items to the core
+// and repeat. The search has no B&B nodes, so opts.MaxNodes is not used
+// (Solve rejects it), opts.TimeLimit and the context stop the core DP
+func knapsackCore(K int32, items itemList, opts Options,
+                  maxvalue *int32, bound *int64) ([]byte, bool) {
+    ctx := contextOf(opts)
+    if opts.TimeLimit > 0 {
+        var cancel context.CancelFunc
+        ctx, cancel = context.WithTimeout(ctx, opts.TimeLimit)
+        defer cancel()
+    }
+    N := len(items)
+    bestset := make([]byte, N)
+    p := newPrefixSums(items)
+    b := p.breakItem(K)
+
+    if b == N {
+        // everything fits
+        for i := 0; i < N; i++ {
+            bestset[i] = 1
+        }
+        *maxvalue = int32(p.V[N])
+        *bound = p.V[N]
+        return bestset, true
+    }
+
+    // items before the break item are the incumbent until the first core
+    // is solved
+    for i := 0; i < b; i++ {
+        bestset[i] = 1
+    }
+    *maxvalue = int32(p.V[b])
+
+    inCore := make([]bool, N)
+    for i := b - coreInitialHalfSize; i <= b + coreInitialHalfSize; i++ {
+        if i >= 0 && i < N {
+            inCore[i] = true
+        }
+    }
+
+    for {
+        // items before the break item are taken unless they are in the
+        // core, items after it are not
+        var fixedValue, fixedWeight int64
+        core := make([]int, 0)
+        v := make([]int32, 0)
+        w := make([]int32, 0)
+        for i := 0; i < N; i++ {
+            if inCore[i] {
+                core = append(core, i)
+                v = append(v, items[i].value)
+                w = append(w, items[i].weight)
+            } else if i < b {
+                fixedValue += int64(items[i].value)
+                fixedWeight += int64(items[i].weight)
+            }
+        }
+        value, x, err := knapsackPareto(ctx, K - int32(fixedWeight), v, w)
+        if err != nil {
+            // the incumbent of the previous core is still valid
+            opts.logln("search canceled:", err)
+            root := node{-1, 0, 0, 0, 0, nil}
+            *bound = root.estimate(K, int32(N), items)
+            return bestset, false
+        }
+        *maxvalue = int32(fixedValue) + value
+
+        for i := 0; i < N; i++ {
+            bestset[i] = 0
+            if !inCore[i] && i < b {
+                bestset[i] = 1
+            }
+        }
+        for j, i := range core {
+            bestset[i] = x[j]
+        }
+
+        // add items outside the core which may improve the solution
+        unfixed := 0
+        for i := 0; i < N; i++ {
+            if !inCore[i] && p.flippedBound(K, items, i, b) > int64(*maxvalue) {
+                inCore[i] = true
+                unfixed++
+            }
+        }
+        opts.logln("core size", len(core), "of", N, "value", *maxvalue,
+                   "unfixed items", unfixed)
+
+        if unfixed == 0 {
+            break
+        }
+    }
+
+    *bound = int64(*maxvalue)
+    return bestset, true
+}
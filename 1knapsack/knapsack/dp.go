@@ -0,0 +1,301 @@
+package knapsack
+
+import "context"
+import "os"
+import "sync"
+
+// Dynamic Programming --------------------------------------------------------
+
+// returns optimal value and selected items; the table is kept in memory,
+// which never fails
+func knapsackDynamicProgramming(K int32, v []int32, w []int32) (int32, []byte) {
+    value, x, _ := knapsackDynamicProgrammingWorkers(context.Background(), K, v, w, 1,
+                                                     newMemoryColumnStore())
+    return value, x
+}
+
+const (
+    // don't split columns into parts smaller than this
+    dpMinPartSize = 4096
+)
+
+// next[k] for k in [from, to) -- best value with item (vj, wj) added to
+// the previous column prev
+func dpNextColumn(prev []int32, next []int32, vj int32, wj int32,
+                  from int32, to int32) {
+    for k := from; k < to; k++ {
+        if wj <= k {
+            next[k] = max(prev[k], vj + prev[k-wj])
+        } else {
+            next[k] = prev[k]
+        }
+    }
+}
+
+// compute capacities 0..K of the next column in parallel (capacity 0 too,
+// items of zero weight fit there): every worker
+// only reads the previous column and writes its own part of the next one,
+// and all of them are done (barrier) before the next item
+func dpNextColumnParallel(prev []int32, next []int32, vj int32, wj int32,
+                          workers int) {
+    K := int32(len(next) - 1)
+    part := (K + int32(workers) - 1) / int32(workers)
+    if workers <= 1 || part < dpMinPartSize {
+        dpNextColumn(prev, next, vj, wj, 0, K+1)
+        return
+    }
+
+    var wg sync.WaitGroup
+    for from := int32(0); from <= K; from += part {
+        to := from + part
+        if to > K+1 {
+            to = K+1
+        }
+        wg.Add(1)
+        go func(from, to int32) {
+            defer wg.Done()
+            dpNextColumn(prev, next, vj, wj, from, to)
+        }(from, to)
+    }
+    wg.Wait()
+}
+
+// table DP with columns appended to the store, each column is computed by
+// workers goroutines. The columns already in the store are not recomputed,
+// so the run interrupted at any point is resumed from the last completed
+// column of the table file
+func knapsackDynamicProgrammingWorkers(ctx context.Context, K int32, v []int32,
+                                       w []int32, workers int,
+                                       store columnStore) (int32, []byte, error) {
+    N := int32(len(v))
+
+    // O(k,j) denotes the optimal solution to the knapsack problem with
+    // capacity k and items [1..j], only two columns are kept in memory
+    var O = [][]int32{make([]int32, K+1), make([]int32, K+1)}
+
+    done := store.Len()
+    if done == 0 {
+        if err := store.Append(O[0]); err != nil {
+            return 0, nil, err
+        }
+        done = 1
+    } else if err := store.Load(done-1, O[0]); err != nil {
+        return 0, nil, err
+    }
+
+    // for all items not done yet
+    for j := done; j <= N; j++ {
+        if err := ctx.Err(); err != nil {
+            return 0, nil, err
+        }
+        // for all capacities
+        dpNextColumnParallel(O[0], O[1], v[j-1], w[j-1], workers)
+
+        if err := store.Append(O[1]); err != nil {
+            return 0, nil, err
+        }
+        O[0], O[1] = O[1], O[0]
+    }
+
+    return reconstruct(store, K, w)
+}
+
+// table DP with the store and temporary directory given in opts, the
+// directory is removed after the run; the table file given in opts needs
+// no temporary directory
+func knapsackTableDP(ctx context.Context, K int32, v []int32, w []int32,
+                     opts Options, workers int) (value int32, x []byte, err error) {
+    if err := checkStoreOptions(opts); err != nil {
+        return 0, nil, err
+    }
+    dir := ""
+    if opts.Table == "" {
+        dir, err = os.MkdirTemp(opts.TmpDir, "knapsack-dp-")
+        if err != nil {
+            return 0, nil, err
+        }
+        defer os.RemoveAll(dir)
+    }
+
+    store, err := openColumnStore(opts, dir, K, v, w)
+    if err != nil {
+        return 0, nil, err
+    }
+    value, x, err = knapsackDynamicProgrammingWorkers(ctx, K, v, w, workers, store)
+    if cerr := store.Close(); err == nil {
+        err = cerr
+    }
+    return value, x, err
+}
+
+// Divide and conquer DP ------------------------------------------------------
+
+// O[k] -- best value of items v, w with capacity k (k = 0..len(O)-1);
+// O must be zeroed, only one column is kept
+func dpColumn(ctx context.Context, v []int32, w []int32, O []int32) error {
+    K := int32(len(O) - 1)
+    for j := 0; j < len(v); j++ {
+        if err := ctx.Err(); err != nil {
+            return err
+        }
+        // go backwards, so that O[k - w] is still the previous column
+        for k := K; k >= w[j]; k-- {
+            O[k] = max(O[k], v[j] + O[k-w[j]])
+        }
+    }
+    return nil
+}
+
+// select the best items of v, w with capacity K into x
+// (Hirschberg-style): split items in two halves, find the best split of
+// capacity between them using only the last DP column of each half and
+// solve halves recursively. Memory is O(K), time is at most twice the
+// time of the full table DP
+func divideAndConquer(ctx context.Context, K int32, v []int32, w []int32,
+                      x []byte) error {
+    N := len(v)
+    if N == 0 {
+        return nil
+    }
+    if N == 1 {
+        if w[0] <= K && v[0] > 0 {
+            x[0] = 1
+        }
+        return nil
+    }
+
+    // there is no need to consider capacity above the total weight
+    var total int64
+    for j := 0; j < N; j++ {
+        total += int64(w[j])
+    }
+    if total <= int64(K) {
+        for j := 0; j < N; j++ {
+            x[j] = 1
+        }
+        return nil
+    }
+
+    mid := N / 2
+    first := make([]int32, K+1)
+    second := make([]int32, K+1)
+    if err := dpColumn(ctx, v[:mid], w[:mid], first); err != nil {
+        return err
+    }
+    if err := dpColumn(ctx, v[mid:], w[mid:], second); err != nil {
+        return err
+    }
+
+    var split, k int32
+    best := int32(-1)
+    for k = 0; k <= K; k++ {
+        if first[k] + second[K-k] > best {
+            best = first[k] + second[K-k]
+            split = k
+        }
+    }
+    // release columns before going deeper
+    first, second = nil, nil
+
+    if err := divideAndConquer(ctx, split, v[:mid], w[:mid], x[:mid]); err != nil {
+        return err
+    }
+    return divideAndConquer(ctx, K - split, v[mid:], w[mid:], x[mid:])
+}
+
+// returns optimal value and selected items, no DP table is stored
+func knapsackDivideAndConquer(ctx context.Context, K int32, v []int32,
+                              w []int32) (int32, []byte, error) {
+    x := make([]byte, len(v))
+    if err := divideAndConquer(ctx, K, v, w, x); err != nil {
+        return 0, nil, err
+    }
+
+    var value int32
+    for j := 0; j < len(v); j++ {
+        if x[j] == 1 {
+            value += v[j]
+        }
+    }
+    return value, x, nil
+}
+
+// Sparse DP ------------------------------------------------------------------
+
+// item selection is shared between states as a linked list, only the
+// states on the current frontier keep their lists alive
+type paretoItem struct {
+    item int32
+    prev *paretoItem
+}
+
+// (weight, value) state of the sparse DP
+type paretoState struct {
+    weight int32
+    value int32
+    sel *paretoItem // selected items
+}
+
+// add item (vj, wj) to the frontier: merge states with and without the item
+// (both sorted by weight) and keep only not dominated ones, i.e. with value
+// larger than any state of less or equal weight
+func paretoMerge(K int32, states []paretoState, j int32,
+                 vj int32, wj int32) []paretoState {
+    n := len(states)
+    merged := make([]paretoState, 0, n * 2)
+    var a, b int
+    for a < n || b < n {
+        var s paretoState
+        include := false
+        if b >= n || states[b].weight + wj > K ||
+            (a < n && states[a].weight <= states[b].weight + wj) {
+            if a >= n {
+                break
+            }
+            s = states[a]
+            a++
+        } else {
+            s = paretoState{states[b].weight + wj, states[b].value + vj, states[b].sel}
+            include = true
+            b++
+        }
+
+        last := len(merged) - 1
+        if last >= 0 && s.value <= merged[last].value {
+            continue // dominated
+        }
+        if include {
+            s.sel = &paretoItem{j, s.sel}
+        }
+        if last >= 0 && merged[last].weight == s.weight {
+            merged[last] = s
+        } else {
+            merged = append(merged, s)
+        }
+    }
+    return merged
+}
+
+// DP over the Pareto frontier of not dominated (weight, value) states
+// instead of all capacities 0..K; works well when K is huge, but the number
+// of distinct weights is small
+func knapsackPareto(ctx context.Context, K int32, v []int32,
+                    w []int32) (int32, []byte, error) {
+    N := int32(len(v))
+    states := []paretoState{paretoState{0, 0, nil}}
+    var j int32
+    for j = 0; j < N; j++ {
+        if err := ctx.Err(); err != nil {
+            return 0, nil, err
+        }
+        states = paretoMerge(K, states, j, v[j], w[j])
+    }
+
+    // values are increasing with weights, the last state is the best
+    best := states[len(states)-1]
+    x := make([]byte, N)
+    for sel := best.sel; sel != nil; sel = sel.prev {
+        x[sel.item] = 1
+    }
+    return best.value, x, nil
+}
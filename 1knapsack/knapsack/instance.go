@@ -0,0 +1,403 @@
+package knapsack
+
+import "fmt"
+import "os"
+import "strconv"
+import "math"
+import "math/rand"
+import "io"
+import "bufio"
+
+// Instance -------------------------------------------------------------------
+
+// input formats
+const (
+    // n K
+    // v w (n lines)
+    // [conflicts m
+    //  i j (m lines, items i and j, 0-based, can't be taken together)]
+    FORMAT_PLAIN = "plain"
+    // multidim n m
+    // K1 .. Km
+    // v w1 .. wm (n lines)
+    FORMAT_MULTIDIM = "multidim"
+    // grouped n K
+    // v w g (n lines, exactly one item of each group g = 0, 1, ... is selected)
+    FORMAT_GROUPED = "grouped"
+    // bounded n K
+    // v w c (n lines, up to c copies of each item)
+    FORMAT_BOUNDED = "bounded"
+    // unbounded n K
+    // v w (n lines, any number of copies of each item)
+    FORMAT_UNBOUNDED = "unbounded"
+)
+
+type Instance struct {
+    Format string
+    K []int32     // capacity in each dimension
+    V []int32
+    W [][]int32   // W[d][i] -- weight of item i in dimension d
+    Group []int32 // group of each item (grouped format only)
+    Count []int32 // copies of each item (bounded and unbounded formats only)
+    Conflicts [][2]int32 // items which can't be taken together (plain format only)
+}
+
+// ReadFile reads the instance in any of the input formats
+func ReadFile(filename string) (*Instance, error) {
+    file, err := os.Open(filename)
+    if err != nil {
+        return nil, err
+    }
+    defer file.Close()
+    return Read(file)
+}
+
+// Read reads the instance, the format is detected by the first word: plain
+// instances start with n
+func Read(file io.Reader) (*Instance, error) {
+    var err error
+    var first string
+    var n, m, i, d int
+    // truncated or garbled input must not be taken for zeros
+    scan := func(what string, args ...interface{}) error {
+        if _, err := fmt.Fscan(file, args...); err != nil {
+            return fmt.Errorf("%s: %v", what, err)
+        }
+        return nil
+    }
+    if err := scan("format", &first); err != nil {
+        return nil, err
+    }
+
+    inst := &Instance{Format: first}
+    switch first {
+    case FORMAT_MULTIDIM:
+        err = scan("number of items and dimensions", &n, &m)
+    case FORMAT_GROUPED, FORMAT_BOUNDED, FORMAT_UNBOUNDED:
+        err = scan("number of items", &n)
+        m = 1
+    default:
+        inst.Format = FORMAT_PLAIN
+        n, err = strconv.Atoi(first)
+        if err != nil {
+            return nil, fmt.Errorf("unknown format %q", first)
+        }
+        m = 1
+    }
+    if err != nil {
+        return nil, err
+    }
+    if n < 0 {
+        return nil, fmt.Errorf("number of items %d is negative", n)
+    }
+    if m < 1 {
+        return nil, fmt.Errorf("number of dimensions %d, must be at least 1", m)
+    }
+
+    inst.K = make([]int32, m)
+    for d = 0; d < m; d++ {
+        if err := scan(fmt.Sprintf("capacity %d", d), &inst.K[d]); err != nil {
+            return nil, err
+        }
+        if inst.K[d] < 0 {
+            return nil, fmt.Errorf("capacity %d is negative", inst.K[d])
+        }
+    }
+
+    inst.V = make([]int32, n)
+    inst.W = make([][]int32, m)
+    for d = 0; d < m; d++ {
+        inst.W[d] = make([]int32, n)
+    }
+    if inst.Format == FORMAT_GROUPED {
+        inst.Group = make([]int32, n)
+    }
+    if inst.Format == FORMAT_BOUNDED || inst.Format == FORMAT_UNBOUNDED {
+        inst.Count = make([]int32, n)
+    }
+
+    for i = 0; i < n; i++ {
+        item := fmt.Sprintf("item %d", i)
+        if err := scan(item + " value", &inst.V[i]); err != nil {
+            return nil, err
+        }
+        if inst.V[i] < 0 {
+            return nil, fmt.Errorf("%s value %d is negative", item, inst.V[i])
+        }
+        for d = 0; d < m; d++ {
+            if err := scan(item + " weight", &inst.W[d][i]); err != nil {
+                return nil, err
+            }
+            if inst.W[d][i] < 0 {
+                return nil, fmt.Errorf("%s weight %d is negative", item, inst.W[d][i])
+            }
+        }
+        if inst.Format == FORMAT_GROUPED {
+            if err := scan(item + " group", &inst.Group[i]); err != nil {
+                return nil, err
+            }
+            if inst.Group[i] < 0 {
+                return nil, fmt.Errorf("%s group %d is negative", item, inst.Group[i])
+            }
+        }
+        if inst.Format == FORMAT_BOUNDED {
+            if err := scan(item + " count", &inst.Count[i]); err != nil {
+                return nil, err
+            }
+            if inst.Count[i] < 0 {
+                return nil, fmt.Errorf("%s count %d is negative", item, inst.Count[i])
+            }
+        }
+        if inst.Format == FORMAT_UNBOUNDED {
+            // no more copies than fit into the knapsack
+            if inst.W[0][i] == 0 {
+                return nil, fmt.Errorf("item %d has zero weight, the value is unbounded", i)
+            }
+            inst.Count[i] = inst.K[0] / inst.W[0][i]
+        }
+    }
+
+    if err := checkTotals(inst); err != nil {
+        return nil, err
+    }
+
+    if inst.Format == FORMAT_PLAIN {
+        var section string
+        if k, _ := fmt.Fscan(file, &section); k == 1 {
+            if section != "conflicts" {
+                return nil, fmt.Errorf("unknown section %q", section)
+            }
+            if err := readConflicts(file, inst); err != nil {
+                return nil, err
+            }
+        }
+    }
+    return inst, nil
+}
+
+// values and weights of all copies of all items must fit into int32, the
+// solvers sum them up without overflow checks
+func checkTotals(inst *Instance) error {
+    copies := func(i int) int64 {
+        if inst.Count == nil {
+            return 1
+        }
+        return int64(inst.Count[i])
+    }
+    var value int64
+    weight := make([]int64, len(inst.W))
+    for i := range inst.V {
+        value += copies(i) * int64(inst.V[i])
+        for d := range inst.W {
+            weight[d] += copies(i) * int64(inst.W[d][i])
+        }
+        if value > math.MaxInt32 {
+            return fmt.Errorf("total value of items 0..%d overflows int32", i)
+        }
+        for d := range weight {
+            if weight[d] > math.MaxInt32 {
+                return fmt.Errorf("total weight %d of items 0..%d overflows int32", d, i)
+            }
+        }
+    }
+    return nil
+}
+
+func readConflicts(file io.Reader, inst *Instance) error {
+    var m int
+    if _, err := fmt.Fscan(file, &m); err != nil {
+        return fmt.Errorf("number of conflicts: %v", err)
+    }
+    n := int32(len(inst.V))
+    inst.Conflicts = make([][2]int32, m)
+    for e := 0; e < m; e++ {
+        i, j := &inst.Conflicts[e][0], &inst.Conflicts[e][1]
+        if _, err := fmt.Fscan(file, i, j); err != nil {
+            return fmt.Errorf("conflict %d: %v", e, err)
+        }
+        if *i < 0 || *i >= n || *j < 0 || *j >= n || *i == *j {
+            return fmt.Errorf("conflict %d: items %d and %d, must be different items 0..%d",
+                              e, *i, *j, n-1)
+        }
+    }
+    return nil
+}
+
+// returns capacity, values and weights of plain instance
+func readInstance(filename string) (int32, []int32, []int32, error) {
+    inst, err := ReadFile(filename)
+    if err != nil {
+        return 0, nil, nil, err
+    }
+    if inst.Format != FORMAT_PLAIN {
+        return 0, nil, nil, fmt.Errorf("%s is not a plain knapsack instance", filename)
+    }
+    return inst.K[0], inst.V, inst.W[0], nil
+}
+
+// Instance generator ---------------------------------------------------------
+
+// classes of Pisinger's generator, weights are uniform in 1..R
+const (
+    // values are uniform in 1..R
+    CLASS_UNCORRELATED = "uncorrelated"
+    // values are uniform in w-R/10..w+R/10 (at least 1)
+    CLASS_WEAKLY_CORRELATED = "weakly"
+    // values are w+R/10
+    CLASS_STRONGLY_CORRELATED = "strongly"
+    // values are uniform in 1..R, weights are v+R/10
+    CLASS_INVERSE_STRONGLY_CORRELATED = "inverse"
+    // values are equal to weights
+    CLASS_SUBSET_SUM = "subsetsum"
+)
+
+// Generate returns the plain instance of n items of the class, capacity is
+// the fraction of the total weight; the same seed gives the same instance
+func Generate(class string, n int, R int32, fraction float64,
+              seed int64) (*Instance, error) {
+    if n < 1 || R < 1 {
+        return nil, fmt.Errorf("n %d and range %d must be positive", n, R)
+    }
+    rng := rand.New(rand.NewSource(seed))
+    v := make([]int32, n)
+    w := make([]int32, n)
+    for i := 0; i < n; i++ {
+        w[i] = 1 + rng.Int31n(R)
+        switch class {
+        case CLASS_UNCORRELATED:
+            v[i] = 1 + rng.Int31n(R)
+        case CLASS_WEAKLY_CORRELATED:
+            v[i] = w[i] - R/10 + rng.Int31n(2*(R/10) + 1)
+            if v[i] < 1 {
+                v[i] = 1
+            }
+        case CLASS_STRONGLY_CORRELATED:
+            v[i] = w[i] + R/10
+        case CLASS_INVERSE_STRONGLY_CORRELATED:
+            v[i] = w[i]
+            w[i] = v[i] + R/10
+        case CLASS_SUBSET_SUM:
+            v[i] = w[i]
+        default:
+            return nil, fmt.Errorf("unknown class %q", class)
+        }
+    }
+
+    var total int64
+    for i := 0; i < n; i++ {
+        total += int64(w[i])
+    }
+    K := int64(fraction * float64(total))
+    if K < 0 || K > int64(1<<31 - 1) {
+        return nil, fmt.Errorf("capacity %d is out of int32 range", K)
+    }
+    return &Instance{FORMAT_PLAIN, []int32{int32(K)}, v, [][]int32{w}, nil, nil, nil}, nil
+}
+
+// Write writes the plain instance in the input format
+func Write(file io.Writer, inst *Instance) error {
+    if inst.Format != FORMAT_PLAIN {
+        return fmt.Errorf("%s instance can't be written", inst.Format)
+    }
+    out := bufio.NewWriter(file)
+    fmt.Fprintln(out, len(inst.V), inst.K[0])
+    for i := range inst.V {
+        fmt.Fprintln(out, inst.V[i], inst.W[0][i])
+    }
+    return out.Flush()
+}
+
+// Verification ---------------------------------------------------------------
+
+// Verify checks the solution file (in the output format) against the
+// instance: number of items, feasibility and the declared value
+func Verify(inst *Instance, filename string) error {
+    file, err := os.Open(filename)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+
+    tokens := make([]int64, 0)
+    scanner := bufio.NewScanner(file)
+    scanner.Split(bufio.ScanWords)
+    for scanner.Scan() {
+        t, err := strconv.ParseInt(scanner.Text(), 10, 64)
+        if err != nil {
+            return fmt.Errorf("token %d: %q is not an integer", len(tokens) + 1, scanner.Text())
+        }
+        tokens = append(tokens, t)
+    }
+    if err := scanner.Err(); err != nil {
+        return err
+    }
+
+    N := len(inst.V)
+    if len(tokens) < 2 {
+        return fmt.Errorf("missing \"value optimal\" header line")
+    }
+    declared, flag := tokens[0], tokens[1]
+    x := tokens[2:]
+    if flag != 0 && flag != 1 {
+        return fmt.Errorf("optimality flag is %d, must be 0 or 1", flag)
+    }
+    if len(x) != N {
+        return fmt.Errorf("expected %d item values, got %d", N, len(x))
+    }
+
+    var value int64
+    for i := 0; i < N; i++ {
+        maxCopies := int64(1)
+        if inst.Count != nil {
+            maxCopies = int64(inst.Count[i])
+        }
+        if x[i] < 0 || x[i] > maxCopies {
+            return fmt.Errorf("item %d: value %d is out of range 0..%d", i, x[i], maxCopies)
+        }
+        value += x[i] * int64(inst.V[i])
+    }
+
+    for d := 0; d < len(inst.K); d++ {
+        var weight int64
+        for i := 0; i < N; i++ {
+            weight += x[i] * int64(inst.W[d][i])
+        }
+        if weight > int64(inst.K[d]) {
+            if len(inst.K) == 1 {
+                return fmt.Errorf("weight %d exceeds capacity %d", weight, inst.K[d])
+            }
+            return fmt.Errorf("dimension %d: weight %d exceeds capacity %d",
+                              d, weight, inst.K[d])
+        }
+    }
+
+    if inst.Format == FORMAT_GROUPED {
+        var G int32
+        for i := 0; i < N; i++ {
+            if inst.Group[i] + 1 > G {
+                G = inst.Group[i] + 1
+            }
+        }
+        selected := make([]int64, G)
+        for i := 0; i < N; i++ {
+            selected[inst.Group[i]] += x[i]
+        }
+        for g := int32(0); g < G; g++ {
+            if selected[g] != 1 {
+                return fmt.Errorf("group %d: %d items selected, must be exactly 1",
+                                  g, selected[g])
+            }
+        }
+    }
+
+    for _, e := range inst.Conflicts {
+        if x[e[0]] == 1 && x[e[1]] == 1 {
+            return fmt.Errorf("items %d and %d are in conflict", e[0], e[1])
+        }
+    }
+
+    if value != declared {
+        return fmt.Errorf("declared value %d, selected items sum to %d", declared, value)
+    }
+    return nil
+}
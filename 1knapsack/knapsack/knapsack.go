@@ -0,0 +1,295 @@
+// Package knapsack implements exact and heuristic solvers of 0/1 knapsack
+// and its multi-dimensional, multiple-choice, bounded and unbounded variants
+package knapsack
+
+import "context"
+import "errors"
+import "fmt"
+import "log"
+import "time"
+
+const (
+    // a Pareto state costs about as much time as this many cells of the
+    // divide and conquer DP, auto alg compares the costs to choose the DP
+    autoParetoStateCost = 4
+)
+
+// functions which Go developers should have implemented but happened
+// to be too lazy and religious to do so
+
+func max(a int32, b int32) (r int32) {
+    if a > b {
+        return a
+    } else {
+        return b
+    }
+}
+
+// solver parameters, zero value means no limits
+type Options struct {
+    TimeLimit time.Duration   // stop search after this time (0 -- no limit)
+    MaxNodes int64            // stop search after expanding this many nodes (0 -- no limit)
+    LogInterval time.Duration // how often to log search progress (0 -- never)
+    Workers int               // number of parallel B&B workers
+    Seed int64                // random seed, same seed gives same results
+    Store string              // where DP table columns are kept: memory, gzip or mmap
+    TmpDir string             // directory for DP table files ("" -- system default)
+    Table string              // DP table file kept to resume the run ("" -- temporary)
+    Logger *log.Logger        // progress and diagnostics (nil -- no logging)
+    ctx context.Context       // search stops when it is canceled (nil -- never)
+}
+
+// log through opts.Logger, if any
+func (opts Options) logln(v ...interface{}) {
+    if opts.Logger != nil {
+        opts.Logger.Println(v...)
+    }
+}
+
+// DP choice ------------------------------------------------------------------
+
+// returns optimal value and selected items, or the error if ctx is canceled
+type dynamicProgrammingFunc func(ctx context.Context, K int32, v []int32,
+                                 w []int32) (int32, []byte, error)
+
+// memory required for the full DP table, MB
+func dpEstimatedMemory(K int32, n int32) int {
+    return (int(K+1) * int(n+1) * 4 + int(n)) / 1024 / 1024
+}
+
+// cells computed by divide and conquer DP: n * (K+1) for the values and
+// about as many for the reconstruction
+func divideAndConquerCost(K int32, n int32) int64 {
+    return 2 * int64(n) * int64(K+1)
+}
+
+// upper bound of the number of states of Pareto DP: after item j the
+// frontier has no more states than distinct weights (K+1 or total weight
+// of items 0..j plus 1), distinct values or subsets of items 0..j
+func paretoEstimatedStates(K int32, v []int32, w []int32) int64 {
+    var states, value, weight int64
+    subsets := int64(1)
+    for j := range v {
+        value += int64(v[j])
+        weight += int64(w[j])
+        if subsets < int64(K) + 1 {
+            subsets *= 2
+        }
+        size := int64(K) + 1
+        for _, limit := range []int64{value + 1, weight + 1, subsets} {
+            if limit < size {
+                size = limit
+            }
+        }
+        states += size
+    }
+    return states
+}
+
+// both divide and conquer and Pareto DP need little memory, so the auto
+// alg chooses the one expected to be faster
+func paretoIsFaster(K int32, v []int32, w []int32) bool {
+    pareto := autoParetoStateCost * paretoEstimatedStates(K, v, w)
+    return pareto < divideAndConquerCost(K, int32(len(v)))
+}
+
+// API ------------------------------------------------------------------------
+
+// Result of a solver
+type Result struct {
+    Value int32
+    Selected []int32 // copies of each item taken (0 or 1 unless bounded or unbounded)
+    Optimal bool     // the value is proven to be optimal
+    Bound int64      // upper bound of the optimal value (-1 -- not known)
+}
+
+var ErrInfeasible = errors.New("no feasible solution")
+
+// search is stopped by opts.ctx
+func withContext(ctx context.Context, opts Options) Options {
+    opts.ctx = ctx
+    return opts
+}
+
+// context of the search, if any
+func contextOf(opts Options) context.Context {
+    if opts.ctx == nil {
+        return context.Background()
+    }
+    return opts.ctx
+}
+
+func selectedResult(value int32, x []byte, optimal bool, bound int64) Result {
+    selected := make([]int32, len(x))
+    for i := range x {
+        selected[i] = int32(x[i])
+    }
+    return Result{value, selected, optimal, bound}
+}
+
+func dpResult(ctx context.Context, inst *Instance,
+              dp dynamicProgrammingFunc) (Result, error) {
+    value, x, err := dp(ctx, inst.K[0], inst.V, inst.W[0])
+    if err != nil {
+        return Result{}, err
+    }
+    // DP table is complete, so the value is always optimal and equal to
+    // the upper bound
+    return selectedResult(value, x, true, int64(value)), nil
+}
+
+func branchAndBoundResult(ctx context.Context, inst *Instance, opts Options,
+                          bnb branchAndBoundFunc) Result {
+    value, x, optimal, bound := branchAndBound(inst.K[0], inst.V, inst.W[0],
+                                               withContext(ctx, opts), bnb)
+    return selectedResult(value, x, optimal, bound)
+}
+
+// SolveDP solves the instance with DP: divide and conquer DP for plain
+// instances and DP over split copies for bounded and unbounded ones. DP has
+// no intermediate solution, so only the error is returned if ctx is
+// canceled
+func SolveDP(ctx context.Context, inst *Instance) (Result, error) {
+    if len(inst.Conflicts) > 0 {
+        return Result{}, fmt.Errorf("instance with conflicts can't be solved with DP")
+    }
+    switch inst.Format {
+    case FORMAT_PLAIN:
+        return dpResult(ctx, inst, knapsackDivideAndConquer)
+    case FORMAT_BOUNDED, FORMAT_UNBOUNDED:
+        value, counts, err := knapsackBoundedDP(ctx, inst.K[0], inst.V, inst.W[0], inst.Count)
+        if err != nil {
+            return Result{}, err
+        }
+        return Result{value, counts, true, int64(value)}, nil
+    }
+    return Result{}, fmt.Errorf("%s instance can't be solved with DP", inst.Format)
+}
+
+// SolveBnB solves the instance with depth-first or best-first B&B. If ctx
+// is canceled or a limit of opts is reached, the best solution found so far
+// is returned and it is not optimal
+func SolveBnB(ctx context.Context, inst *Instance, opts Options) (Result, error) {
+    opts = withContext(ctx, opts)
+    var value int32
+    var x []byte
+    var optimal bool
+
+    switch inst.Format {
+    case FORMAT_PLAIN:
+        if len(inst.Conflicts) > 0 {
+            return branchAndBoundResult(ctx, inst, opts, conflictBranchAndBound(inst.Conflicts)), nil
+        }
+        return branchAndBoundResult(ctx, inst, opts, withReduction(knapsackBranchAndBound)), nil
+    case FORMAT_BOUNDED, FORMAT_UNBOUNDED:
+        value, counts, optimal := knapsackBounded(inst.K[0], inst.V, inst.W[0],
+                                                  inst.Count, opts)
+        bound := int64(-1)
+        if optimal {
+            bound = int64(value)
+        }
+        return Result{value, counts, optimal, bound}, nil
+    case FORMAT_MULTIDIM:
+        value, x, optimal = knapsackMultiDim(inst.K, inst.V, inst.W, opts)
+    default:
+        value, x, optimal = knapsackGrouped(inst.K[0], inst.V, inst.W[0],
+                                            inst.Group, opts)
+    }
+
+    if value < 0 {
+        return Result{}, ErrInfeasible
+    }
+    bound := int64(-1)
+    if optimal {
+        bound = int64(value)
+    }
+    return selectedResult(value, x, optimal, bound), nil
+}
+
+// Solve solves the instance with the given alg:
+//   dp -- divide and conquer DP
+//   dpfile, pdp -- table DP with columns in opts.Store, computed by one or
+//                  opts.Workers goroutines
+//   pareto -- sparse DP over the Pareto frontier
+//   bnb, dfs, pbnb -- best-first, depth-first and parallel B&B
+//   core -- expanding core algorithm, opts.MaxNodes is not supported
+//   heuristic -- greedy and local search, no B&B
+//   auto -- dp or pareto, whichever is expected to be faster
+// B&B is used for unknown algs, multi-dimensional, grouped, bounded and
+// unbounded instances are solved with SolveBnB unless alg is dp, and the
+// instances with conflicts are always solved with SolveBnB
+func Solve(ctx context.Context, inst *Instance, alg string,
+           opts Options) (Result, error) {
+    if len(inst.Conflicts) > 0 {
+        return SolveBnB(ctx, inst, opts)
+    }
+    switch inst.Format {
+    case FORMAT_BOUNDED, FORMAT_UNBOUNDED:
+        if alg == "dp" {
+            return SolveDP(ctx, inst)
+        }
+        return SolveBnB(ctx, inst, opts)
+    case FORMAT_MULTIDIM, FORMAT_GROUPED:
+        return SolveBnB(ctx, inst, opts)
+    }
+    K, v, w := inst.K[0], inst.V, inst.W[0]
+
+    switch alg {
+    case "dp":
+        return SolveDP(ctx, inst)
+    case "dpfile", "pdp":
+        workers := 1
+        if alg == "pdp" {
+            workers = opts.Workers
+        }
+        value, x, err := knapsackTableDP(ctx, K, v, w, opts, workers)
+        if err != nil {
+            return Result{}, err
+        }
+        return selectedResult(value, x, true, int64(value)), nil
+    case "pareto":
+        return dpResult(ctx, inst, knapsackPareto)
+    case "core":
+        if opts.MaxNodes > 0 {
+            return Result{}, fmt.Errorf("core alg has no B&B nodes to limit")
+        }
+        return branchAndBoundResult(ctx, inst, opts, knapsackCore), nil
+    case "dfs":
+        return branchAndBoundResult(ctx, inst, opts, withReduction(knapsackDepthFirst)), nil
+    case "pbnb":
+        return branchAndBoundResult(ctx, inst, opts, withReduction(knapsackParallel)), nil
+    case "heuristic":
+        return branchAndBoundResult(ctx, inst, opts, knapsackHeuristic), nil
+    case "auto":
+        if paretoIsFaster(K, v, w) {
+            return dpResult(ctx, inst, knapsackPareto)
+        }
+        return SolveDP(ctx, inst)
+    }
+    return SolveBnB(ctx, inst, opts)
+}
+
+// ReconstructFromFile restores the solution from the DP table file kept by
+// the completed dpfile or pdp run (opts.Table), nothing but the file is
+// needed
+func ReconstructFromFile(filename string) (Result, error) {
+    value, x, err := reconstructFromFile(filename)
+    if err != nil {
+        return Result{}, err
+    }
+    return selectedResult(value, x, true, int64(value)), nil
+}
+
+// EstimatedMemory returns the memory required by the full DP table and by
+// the divide and conquer DP of the plain instance, MB; other instances are
+// not solved with these DPs, so there is no estimate for them
+func EstimatedMemory(inst *Instance) (int, int, error) {
+    if inst.Format != FORMAT_PLAIN {
+        return 0, 0, fmt.Errorf("DP memory of %s instance is not estimated", inst.Format)
+    }
+    if len(inst.Conflicts) > 0 {
+        return 0, 0, fmt.Errorf("DP memory of instance with conflicts is not estimated")
+    }
+    K, n := inst.K[0], int32(len(inst.V))
+    return dpEstimatedMemory(K, n), (int(K+1) * 4 * 2 + int(n)) / 1024 / 1024, nil
+}
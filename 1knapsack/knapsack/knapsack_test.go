@@ -0,0 +1,888 @@
+package knapsack
+
+import "testing"
+import "context"
+import "fmt"
+import "os"
+import "math/rand"
+import "runtime"
+import "sort"
+import "path/filepath"
+import "strings"
+import "time"
+import "bytes"
+import "log"
+
+// instances small enough for the DP table
+var testFiles = []string{
+    "../data/ks_4_0",
+    "../data/test",
+    "../data/ks_19_0",
+    "../data/ks_30_0",
+    "../data/ks_40_0",
+    "../data/ks_45_0",
+    "../data/ks_50_1",
+    "../data/ks_60_0",
+    "../data/ks_100_0",
+    "../data/ks_100_2",
+}
+
+func readTestInstance(t *testing.T, filename string) (int32, []int32, []int32) {
+    K, v, w, err := readInstance(filename)
+    if err != nil {
+        t.Fatal("Cannot open file", filename, err)
+    }
+    return K, v, w
+}
+
+func solutionValue(t *testing.T, filename string, K int32, v []int32, w []int32,
+                   x []byte) int32 {
+    var value, weight int32
+    for i := 0; i < len(x); i++ {
+        if x[i] == 1 {
+            value += v[i]
+            weight += w[i]
+        }
+    }
+    if weight > K {
+        t.Fatal(filename, "weight", weight, "exceeds capacity", K)
+    }
+    return value
+}
+
+func TestParallelDeterministic(t *testing.T) {
+    K, v, w := readTestInstance(t, "../data/ks_100_0")
+    opts := Options{MaxNodes: 100000, Workers: 4, Seed: 1}
+    value, x, _, _ := branchAndBound(K, v, w, opts, knapsackParallel)
+    for run := 0; run < 3; run++ {
+        other, y, _, _ := branchAndBound(K, v, w, opts, knapsackParallel)
+        if other != value || string(x) != string(y) {
+            t.Fatal("run", run, "value", other, "!= first run value", value)
+        }
+    }
+}
+
+// the node limit is split between the workers of a round, not checked
+// between the rounds only
+func TestParallelNodeLimit(t *testing.T) {
+    K, v, w := readTestInstance(t, "../data/ks_100_0")
+    for _, limit := range []int64{1, 7, 1000} {
+        var out bytes.Buffer
+        opts := Options{MaxNodes: limit, Workers: 4, Seed: 1,
+                        LogInterval: time.Hour, Logger: log.New(&out, "", 0)}
+        branchAndBound(K, v, w, opts, knapsackParallel)
+        var nodes int64
+        lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+        fmt.Sscanf(lines[len(lines) - 1], "nodes %d", &nodes)
+        if nodes != limit {
+            t.Error("expanded", nodes, "nodes with limit", limit)
+        }
+    }
+}
+
+func TestRootBoundAboveOptimum(t *testing.T) {
+    for _, filename := range testFiles {
+        K, v, w := readTestInstance(t, filename)
+        optimum, _ := knapsackDynamicProgramming(K, v, w)
+
+        _, _, _, bound := branchAndBound(K, v, w, Options{MaxNodes: 1},
+                                         knapsackBranchAndBound)
+        if bound < int64(optimum) {
+            t.Error(filename, "root bound", bound, "< optimum", optimum)
+        }
+
+        // Martello-Toth bound must be between optimum and Dantzig bound
+        items := make([]node, len(v))
+        for i := 0; i < len(v); i++ {
+            items[i] = node{int32(i), v[i], w[i], -1, 0, nil}
+        }
+        sort.Sort(byValuePerWeight(items))
+        root := node{-1, 0, 0, 0, 0, nil}
+        dantzig := root.estimateDantzig(K, int32(len(items)), items)
+        mt := root.estimate(K, int32(len(items)), items)
+        if mt < int64(optimum) || mt > dantzig {
+            t.Error(filename, "U2 bound", mt, "optimum", optimum, "dantzig", dantzig)
+        }
+    }
+}
+
+// B&B must never prune the node leading to optimal solution: if search space
+// is exhausted the value must match DP, otherwise the bound must be valid
+func TestBranchAndBoundMatchesDP(t *testing.T) {
+    methods := map[string]branchAndBoundFunc{
+        "bnb": knapsackBranchAndBound,
+        "dfs": knapsackDepthFirst,
+        "core": knapsackCore,
+        "pbnb": knapsackParallel,
+        "bnb+reduction": withReduction(knapsackBranchAndBound),
+        "dfs+reduction": withReduction(knapsackDepthFirst),
+        "pbnb+reduction": withReduction(knapsackParallel),
+        "heuristic": knapsackHeuristic,
+    }
+
+    for _, filename := range testFiles {
+        K, v, w := readTestInstance(t, filename)
+        optimum, x := knapsackDynamicProgramming(K, v, w)
+        if value := solutionValue(t, filename, K, v, w, x); value != optimum {
+            t.Fatal(filename, "dp selected value", value, "!= optimum", optimum)
+        }
+
+        // the search stopped by the node limit claims optimality only if its
+        // bound proves it
+        for alg, bnb := range methods {
+            for _, maxNodes := range []int64{1, 100, 1000000} {
+                value, x, optimal, bound := branchAndBound(K, v, w,
+                                                           Options{MaxNodes: maxNodes}, bnb)
+                if selected := solutionValue(t, filename, K, v, w, x); selected != value {
+                    t.Error(filename, alg, "selected value", selected, "!= value", value)
+                }
+                if optimal && (value != optimum || bound != int64(optimum)) {
+                    t.Error(filename, alg, maxNodes, "nodes optimal value", value,
+                            "bound", bound, "!= dp", optimum)
+                }
+                if value > optimum || bound < int64(optimum) {
+                    t.Error(filename, alg, maxNodes, "nodes value", value, "bound", bound,
+                            "inconsistent with dp", optimum)
+                }
+            }
+        }
+    }
+}
+
+// interrupted table DP must be resumed from the last completed column,
+// ignoring the partially written one
+func TestDPTableResume(t *testing.T) {
+    ctx := context.Background()
+    K, v, w := readTestInstance(t, "../data/ks_45_0")
+    optimum, x := knapsackDynamicProgramming(K, v, w)
+
+    filename := filepath.Join(t.TempDir(), dpTableFile)
+    opts := Options{Store: STORE_GZIP, Table: filename}
+    if _, _, err := knapsackTableDP(ctx, K, v, w, opts, 1); err != nil {
+        t.Fatal(err)
+    }
+
+    // pretend the run was interrupted while writing column 20
+    table, err := openGzipColumnStore(filename)
+    if err != nil {
+        t.Fatal(err)
+    }
+    table.done = 20
+    if err := writeAt(table.file, table.donePosition(), table.done); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := table.file.WriteAt([]byte("garbage"), table.end()); err != nil {
+        t.Fatal(err)
+    }
+    table.Close()
+
+    value, y, err := knapsackTableDP(ctx, K, v, w, opts, 2)
+    if err != nil || value != optimum || string(x) != string(y) {
+        t.Error("resumed value", value, "!= optimum", optimum, err)
+    }
+    value, y, err = reconstructFromFile(filename)
+    if err != nil || value != optimum || string(x) != string(y) {
+        t.Error("restored value", value, "!= optimum", optimum, err)
+    }
+}
+
+// every store gives the same table, and no files are left after the run
+func TestColumnStores(t *testing.T) {
+    ctx := context.Background()
+    for _, filename := range testFiles {
+        K, v, w := readTestInstance(t, filename)
+        optimum, x := knapsackDynamicProgramming(K, v, w)
+
+        for _, store := range []string{STORE_MEMORY, STORE_GZIP, STORE_MMAP} {
+            dir := t.TempDir()
+            opts := Options{Store: store, TmpDir: dir}
+            value, y, err := knapsackTableDP(ctx, K, v, w, opts, 1)
+            if err != nil {
+                t.Fatal(filename, store, err)
+            }
+            if value != optimum || string(x) != string(y) {
+                t.Error(filename, store, "value", value, "!= optimum", optimum)
+            }
+            if files, _ := os.ReadDir(dir); len(files) != 0 {
+                t.Error(filename, store, "left", len(files), "files in", dir)
+            }
+        }
+    }
+
+    invalid := []Options{
+        {Store: "tape"},
+        {Store: STORE_MEMORY, Table: filepath.Join(t.TempDir(), dpTableFile)},
+        {Store: STORE_MMAP, Table: filepath.Join(t.TempDir(), dpTableFile)},
+        {Store: STORE_GZIP, Table: filepath.Join(t.TempDir(), dpTableFile), TmpDir: t.TempDir()},
+    }
+    for _, opts := range invalid {
+        if _, _, err := knapsackTableDP(ctx, 10, []int32{1}, []int32{1}, opts, 1); err == nil {
+            t.Error("store", opts.Store, "table", opts.Table, "tmpdir", opts.TmpDir, "accepted")
+        }
+    }
+}
+
+func TestDPMethodsMatchTable(t *testing.T) {
+    methods := map[string]dynamicProgrammingFunc{
+        "dp": knapsackDivideAndConquer,
+        "pareto": knapsackPareto,
+        "pdp": func(ctx context.Context, K int32, v []int32,
+                    w []int32) (int32, []byte, error) {
+            return knapsackDynamicProgrammingWorkers(ctx, K, v, w, 4, newMemoryColumnStore())
+        },
+    }
+
+    for _, filename := range testFiles {
+        K, v, w := readTestInstance(t, filename)
+        optimum, _ := knapsackDynamicProgramming(K, v, w)
+
+        for alg, dp := range methods {
+            value, x, err := dp(context.Background(), K, v, w)
+            if err != nil {
+                t.Fatal(filename, alg, err)
+            }
+            if value != optimum {
+                t.Error(filename, alg, "value", value, "!= dp", optimum)
+            }
+            if selected := solutionValue(t, filename, K, v, w, x); selected != value {
+                t.Error(filename, alg, "selected value", selected, "!= value", value)
+            }
+        }
+    }
+}
+
+// best value of the multi-dimensional instance by enumeration
+func bruteForceMultiDim(K []int32, v []int32, w [][]int32) int32 {
+    best := int32(0)
+    for mask := 0; mask < 1 << uint(len(v)); mask++ {
+        var value int32
+        feasible := true
+        for d := 0; d < len(K); d++ {
+            var weight int32
+            for i := 0; i < len(v); i++ {
+                if mask & (1 << uint(i)) != 0 {
+                    weight += w[d][i]
+                }
+            }
+            feasible = feasible && weight <= K[d]
+        }
+        for i := 0; i < len(v); i++ {
+            if mask & (1 << uint(i)) != 0 {
+                value += v[i]
+            }
+        }
+        if feasible && value > best {
+            best = value
+        }
+    }
+    return best
+}
+
+// best value of the grouped instance by enumeration (-1 if infeasible)
+func bruteForceGrouped(K int32, v []int32, w []int32, group []int32,
+                       G int32, g int32, weight int32, value int32) int32 {
+    if weight > K {
+        return -1
+    }
+    if g == G {
+        return value
+    }
+    best := int32(-1)
+    for i := 0; i < len(v); i++ {
+        if group[i] == g {
+            best = max(best, bruteForceGrouped(K, v, w, group, G, g + 1,
+                                               weight + w[i], value + v[i]))
+        }
+    }
+    return best
+}
+
+func TestMultiDimMatchesBruteForce(t *testing.T) {
+    r := rand.New(rand.NewSource(1))
+    for test := 0; test < 200; test++ {
+        N := 1 + r.Intn(12)
+        M := 1 + r.Intn(3)
+        K := make([]int32, M)
+        v := make([]int32, N)
+        w := make([][]int32, M)
+        for i := 0; i < N; i++ {
+            v[i] = int32(r.Intn(100))
+        }
+        for d := 0; d < M; d++ {
+            K[d] = int32(r.Intn(200))
+            w[d] = make([]int32, N)
+            for i := 0; i < N; i++ {
+                w[d][i] = int32(r.Intn(60))
+            }
+        }
+
+        optimum := bruteForceMultiDim(K, v, w)
+        value, x, optimal := knapsackMultiDim(K, v, w, Options{})
+        if !optimal || value != optimum {
+            t.Fatal("test", test, "value", value, "optimal", optimal, "!= brute force", optimum)
+        }
+        for d := 0; d < M; d++ {
+            if selected := solutionValue(t, "multidim", K[d], v, w[d], x); selected != value {
+                t.Fatal("test", test, "selected value", selected, "!= value", value)
+            }
+        }
+    }
+}
+
+func TestGroupedMatchesBruteForce(t *testing.T) {
+    r := rand.New(rand.NewSource(1))
+    for test := 0; test < 200; test++ {
+        N := 1 + r.Intn(12)
+        G := 1 + r.Intn(N)
+        K := int32(r.Intn(200))
+        v := make([]int32, N)
+        w := make([]int32, N)
+        group := make([]int32, N)
+        for i := 0; i < N; i++ {
+            v[i] = int32(r.Intn(100))
+            w[i] = int32(r.Intn(60))
+            // every group has at least one item
+            group[i] = int32(i)
+            if i >= G {
+                group[i] = int32(r.Intn(G))
+            }
+        }
+
+        optimum := bruteForceGrouped(K, v, w, group, int32(G), 0, 0, 0)
+        value, x, optimal := knapsackGrouped(K, v, w, group, Options{})
+        if !optimal || value != optimum {
+            t.Fatal("test", test, "value", value, "optimal", optimal, "!= brute force", optimum)
+        }
+        if value < 0 {
+            continue
+        }
+        if selected := solutionValue(t, "grouped", K, v, w, x); selected != value {
+            t.Fatal("test", test, "selected value", selected, "!= value", value)
+        }
+        count := make([]int, G)
+        for i := 0; i < N; i++ {
+            count[group[i]] += int(x[i])
+        }
+        for g := 0; g < G; g++ {
+            if count[g] != 1 {
+                t.Fatal("test", test, "group", g, "has", count[g], "selected items")
+            }
+        }
+    }
+}
+
+func TestReadProblemFormats(t *testing.T) {
+    dir := t.TempDir()
+    multidim := dir + "/multidim"
+    grouped := dir + "/grouped"
+    os.WriteFile(multidim, []byte("multidim 3 2\n10 5\n5 4 1\n6 5 2\n7 1 5\n"), 0644)
+    os.WriteFile(grouped, []byte("grouped 3 10\n5 4 0\n6 5 0\n7 6 1\n"), 0644)
+
+    inst, err := ReadFile(multidim)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if inst.Format != FORMAT_MULTIDIM || len(inst.K) != 2 || inst.K[1] != 5 ||
+        inst.W[1][2] != 5 || inst.V[2] != 7 {
+        t.Fatal("wrong multidim instance", inst)
+    }
+    value, _, _ := knapsackMultiDim(inst.K, inst.V, inst.W, Options{})
+    if value != 11 {
+        t.Error("multidim value", value, "!= 11")
+    }
+
+    inst, err = ReadFile(grouped)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if inst.Format != FORMAT_GROUPED || inst.K[0] != 10 || inst.Group[2] != 1 {
+        t.Fatal("wrong grouped instance", inst)
+    }
+    value, _, _ = knapsackGrouped(inst.K[0], inst.V, inst.W[0], inst.Group, Options{})
+    if value != 12 {
+        t.Error("grouped value", value, "!= 12")
+    }
+    if _, _, err := EstimatedMemory(inst); err == nil {
+        t.Error("DP memory of grouped instance estimated")
+    }
+}
+
+// best value of the bounded instance by enumeration of copies of item i..
+func bruteForceBounded(K int32, v []int32, w []int32, count []int32,
+                       i int, weight int32, value int32) int32 {
+    if weight > K {
+        return -1
+    }
+    if i == len(v) {
+        return value
+    }
+    best := int32(-1)
+    for m := int32(0); m <= count[i]; m++ {
+        best = max(best, bruteForceBounded(K, v, w, count, i + 1,
+                                           weight + m * w[i], value + m * v[i]))
+    }
+    return best
+}
+
+func TestBoundedMatchesBruteForce(t *testing.T) {
+    r := rand.New(rand.NewSource(1))
+    for test := 0; test < 200; test++ {
+        N := 1 + r.Intn(6)
+        K := int32(r.Intn(200))
+        v := make([]int32, N)
+        w := make([]int32, N)
+        count := make([]int32, N)
+        for i := 0; i < N; i++ {
+            v[i] = int32(r.Intn(100))
+            w[i] = int32(1 + r.Intn(60))
+            count[i] = int32(r.Intn(6))
+        }
+
+        optimum := bruteForceBounded(K, v, w, count, 0, 0, 0)
+        dpValue, dpCounts, _ := knapsackBoundedDP(context.Background(), K, v, w, count)
+        value, counts, optimal := knapsackBounded(K, v, w, count, Options{})
+        if dpValue != optimum || !optimal || value != optimum {
+            t.Fatal("test", test, "dp", dpValue, "bnb", value, "optimal", optimal,
+                    "!= brute force", optimum)
+        }
+
+        for _, c := range [][]int32{dpCounts, counts} {
+            var weight, selected int32
+            for i := 0; i < N; i++ {
+                if c[i] < 0 || c[i] > count[i] {
+                    t.Fatal("test", test, "item", i, "copies", c[i], "of", count[i])
+                }
+                weight += c[i] * w[i]
+                selected += c[i] * v[i]
+            }
+            if weight > K || selected != optimum {
+                t.Fatal("test", test, "weight", weight, "value", selected)
+            }
+        }
+    }
+}
+
+// copies pruned by the bound, not enumerated one by one
+func TestBoundedLargeCounts(t *testing.T) {
+    inst, err := Read(strings.NewReader("unbounded 2 100000000\n7 3\n5 2\n"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    opts := Options{MaxNodes: 1000}
+    value, counts, optimal := knapsackBounded(inst.K[0], inst.V, inst.W[0], inst.Count, opts)
+    if value != 250000000 || !optimal || counts[1] != 50000000 {
+        t.Error("value", value, "optimal", optimal, "copies", counts)
+    }
+}
+
+func TestVerifySolution(t *testing.T) {
+    dir := t.TempDir()
+    grouped := dir + "/grouped"
+    os.WriteFile(grouped, []byte("grouped 3 10\n5 4 0\n6 5 0\n7 6 1\n"), 0644)
+    inst, err := ReadFile(grouped)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    solutions := map[string]bool{
+        "12 1\n1 0 1\n": true,
+        "12 0\n1 0 1": true,
+        "13 1\n1 0 1\n": false, // wrong value
+        "12 2\n1 0 1\n": false, // wrong flag
+        "12 1\n1 0\n": false,   // too short
+        "12 1\n1 0 1 0\n": false, // too long
+        "13 1\n0 1 1\n": false, // over capacity
+        "5 1\n1 0 0\n": false,  // group 1 is not selected
+        "11 1\n1 1 0\n": false, // two items of group 0
+        "12 1\n1 0 x\n": false, // not a number
+    }
+    for solution, valid := range solutions {
+        filename := dir + "/solution"
+        os.WriteFile(filename, []byte(solution), 0644)
+        err := Verify(inst, filename)
+        if valid && err != nil {
+            t.Errorf("%q must be valid: %v", solution, err)
+        }
+        if !valid && err == nil {
+            t.Errorf("%q must be invalid", solution)
+        }
+    }
+}
+
+// best value of the instance with conflicts by enumeration of subsets
+func bruteForceConflicts(K int32, v []int32, w []int32, edges [][2]int32) int32 {
+    best := int32(0)
+    for mask := 0; mask < 1 << len(v); mask++ {
+        var value, weight int32
+        for i := range v {
+            if mask & (1 << i) != 0 {
+                value += v[i]
+                weight += w[i]
+            }
+        }
+        feasible := weight <= K
+        for _, e := range edges {
+            if mask & (1 << e[0]) != 0 && mask & (1 << e[1]) != 0 {
+                feasible = false
+            }
+        }
+        if feasible {
+            best = max(best, value)
+        }
+    }
+    return best
+}
+
+func checkConflicts(t *testing.T, name string, K int32, v []int32, w []int32,
+                    edges [][2]int32) {
+    optimum := bruteForceConflicts(K, v, w, edges)
+    value, x, optimal, bound := branchAndBound(K, v, w, Options{},
+                                               conflictBranchAndBound(edges))
+    if !optimal || value != optimum || bound != int64(optimum) {
+        t.Fatal(name, "value", value, "optimal", optimal, "bound", bound,
+                "!= brute force", optimum)
+    }
+    if selected := solutionValue(t, name, K, v, w, x); selected != value {
+        t.Fatal(name, "selected value", selected, "!= value", value)
+    }
+    for _, e := range edges {
+        if x[e[0]] == 1 && x[e[1]] == 1 {
+            t.Fatal(name, "items", e[0], "and", e[1], "in conflict are selected")
+        }
+    }
+}
+
+func TestConflicts(t *testing.T) {
+    // the two best items are in conflict
+    checkConflicts(t, "pair", 3, []int32{10, 10, 1}, []int32{1, 1, 1},
+                   [][2]int32{{0, 1}})
+    // triangle: only one of the first three items is taken
+    checkConflicts(t, "triangle", 10, []int32{5, 6, 7, 3}, []int32{2, 2, 2, 2},
+                   [][2]int32{{0, 1}, {1, 2}, {0, 2}})
+    // star: the center or all the leaves
+    checkConflicts(t, "star", 10, []int32{10, 4, 4, 4}, []int32{1, 3, 3, 3},
+                   [][2]int32{{0, 1}, {0, 2}, {0, 3}})
+    // every item is in conflict with the next one
+    checkConflicts(t, "path", 100, []int32{5, 9, 5, 9, 5}, []int32{1, 1, 1, 1, 1},
+                   [][2]int32{{0, 1}, {1, 2}, {2, 3}, {3, 4}})
+
+    r := rand.New(rand.NewSource(1))
+    for test := 0; test < 200; test++ {
+        N := 1 + r.Intn(10)
+        K := int32(r.Intn(200))
+        v := make([]int32, N)
+        w := make([]int32, N)
+        for i := 0; i < N; i++ {
+            v[i] = int32(r.Intn(100))
+            w[i] = int32(1 + r.Intn(60))
+        }
+        edges := make([][2]int32, 0)
+        for i := 0; i < N; i++ {
+            for j := i+1; j < N; j++ {
+                if r.Intn(4) == 0 {
+                    edges = append(edges, [2]int32{int32(i), int32(j)})
+                }
+            }
+        }
+        checkConflicts(t, fmt.Sprint("test ", test), K, v, w, edges)
+    }
+}
+
+func TestReadConflicts(t *testing.T) {
+    dir := t.TempDir()
+    filename := dir + "/conflicts"
+    os.WriteFile(filename, []byte("3 3\n10 1\n10 1\n1 1\nconflicts 1\n0 1\n"), 0644)
+    inst, err := ReadFile(filename)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(inst.Conflicts) != 1 || inst.Conflicts[0] != [2]int32{0, 1} {
+        t.Fatal("conflicts", inst.Conflicts)
+    }
+
+    r, err := Solve(context.Background(), inst, "dp", Options{})
+    if err != nil || r.Value != 11 || !r.Optimal {
+        t.Error("value", r.Value, "optimal", r.Optimal, "!= 11", err)
+    }
+    if _, err := SolveDP(context.Background(), inst); err == nil {
+        t.Error("instance with conflicts solved with DP")
+    }
+
+    solution := dir + "/solution"
+    os.WriteFile(solution, []byte("20 0\n1 1 0\n"), 0644)
+    if err := Verify(inst, solution); err == nil {
+        t.Error("solution with items in conflict is valid")
+    }
+
+    invalid := []string{
+        "3 3\n10 1\n10 1\n1 1\nconflicts 1\n0 3\n",
+        "3 3\n10 1\n10 1\n1 1\nconflicts 1\n1 1\n",
+        "3 3\n10 1\n10 1\n1 1\nconflicts 2\n0 1\n",
+        "3 3\n10 1\n10 1\n1 1\nedges 1\n0 1\n",
+    }
+    for _, data := range invalid {
+        os.WriteFile(filename, []byte(data), 0644)
+        if _, err := ReadFile(filename); err == nil {
+            t.Errorf("%q must be invalid", data)
+        }
+    }
+}
+
+// truncated, garbled or out of range input is an error, not a panic or
+// a zero filled instance
+func TestReadInvalid(t *testing.T) {
+    invalid := []string{
+        "",
+        "3",
+        "3 10\n5 4\n6 5\n",
+        "3 10\n5 4\n6 x\n7 1\n",
+        "-1 10\n",
+        "multidim 2 0\n",
+        "multidim 2 2\n10\n",
+        "grouped 2 10\n5 4 0\n6 5 -1\n",
+        "grouped 2 10\n5 4 0\n6 5\n",
+        "bounded 1 10\n5 4 -2\n",
+        "unbounded -3 10\n",
+        "2 -5\n5 1\n3 1\n",
+        "2 5\n5 -1\n3 1\n",
+        "2 5\n-5 1\n3 1\n",
+        "multidim 1 2\n5 -5\n1 1 1\n",
+        "multidim 1 2\n5 5\n1 1 -1\n",
+        "grouped 1 5\n-1 1 0\n",
+        "bounded 1 5\n1 -1 2\n",
+        "unbounded 1 2000000000\n1000 1\n",
+        "bounded 2 10\n2000000000 1 1\n2000000000 1 1\n",
+        "2 2000000000\n1 2000000000\n1 2000000000\n",
+    }
+    for _, data := range invalid {
+        if inst, err := Read(strings.NewReader(data)); err == nil {
+            t.Errorf("%q must be invalid, read %v", data, inst)
+        }
+    }
+}
+
+func TestGenerate(t *testing.T) {
+    classes := []string{CLASS_UNCORRELATED, CLASS_WEAKLY_CORRELATED,
+                        CLASS_STRONGLY_CORRELATED, CLASS_INVERSE_STRONGLY_CORRELATED,
+                        CLASS_SUBSET_SUM}
+    filename := t.TempDir() + "/instance"
+    for _, class := range classes {
+        inst, err := Generate(class, 50, 100, 0.5, 7)
+        if err != nil {
+            t.Fatal(class, err)
+        }
+        again, _ := Generate(class, 50, 100, 0.5, 7)
+        if fmt.Sprint(inst) != fmt.Sprint(again) {
+            t.Error(class, "differs for the same seed")
+        }
+
+        var total int64
+        for i := range inst.V {
+            v, w := inst.V[i], inst.W[0][i]
+            total += int64(w)
+            if v < 1 || w < 1 ||
+                (class == CLASS_WEAKLY_CORRELATED && (v < w - 10 || v > w + 10)) ||
+                (class == CLASS_STRONGLY_CORRELATED && v != w + 10) ||
+                (class == CLASS_INVERSE_STRONGLY_CORRELATED && w != v + 10) ||
+                (class == CLASS_SUBSET_SUM && v != w) {
+                t.Error(class, "item", i, "value", v, "weight", w)
+            }
+        }
+        if int64(inst.K[0]) != total / 2 {
+            t.Error(class, "capacity", inst.K[0], "total weight", total)
+        }
+
+        // written instance is read back
+        file, _ := os.Create(filename)
+        if err := Write(file, inst); err != nil {
+            t.Fatal(class, err)
+        }
+        file.Close()
+        read, err := ReadFile(filename)
+        if err != nil || fmt.Sprint(read) != fmt.Sprint(inst) {
+            t.Error(class, "read", read, "!= written", inst, err)
+        }
+    }
+
+    if _, err := Generate("correlated", 10, 100, 0.5, 1); err == nil {
+        t.Error("unknown class accepted")
+    }
+}
+
+func checkResult(t *testing.T, name string, inst *Instance, r Result, optimum int32) {
+    var value, weight int32
+    for i, copies := range r.Selected {
+        value += copies * inst.V[i]
+        weight += copies * inst.W[0][i]
+    }
+    if value != r.Value || weight > inst.K[0] {
+        t.Error(name, "selected value", value, "weight", weight, "result", r.Value)
+    }
+    if r.Value > optimum || (r.Optimal && r.Value != optimum) ||
+        (r.Bound >= 0 && r.Bound < int64(optimum)) {
+        t.Error(name, "value", r.Value, "optimal", r.Optimal, "bound", r.Bound,
+                "inconsistent with optimum", optimum)
+    }
+}
+
+// items of zero weight fit into capacity 0 too, every alg takes them
+func TestZeroWeightItems(t *testing.T) {
+    ctx := context.Background()
+    algs := []string{"dp", "dpfile", "pdp", "pareto", "bnb", "dfs", "pbnb", "core",
+                     "heuristic", "auto"}
+    instances := map[string]int32{
+        "2 0\n5 0\n3 1\n": 5,
+        "3 4\n5 0\n3 1\n4 4\n": 9,
+    }
+    for data, optimum := range instances {
+        inst, err := Read(strings.NewReader(data))
+        if err != nil {
+            t.Fatal(err)
+        }
+        for _, alg := range algs {
+            r, err := Solve(ctx, inst, alg, Options{Workers: 2, Store: STORE_MEMORY})
+            if err != nil {
+                t.Fatal(alg, err)
+            }
+            checkResult(t, fmt.Sprintf("%q %s", data, alg), inst, r, optimum)
+        }
+    }
+
+    // columns split between workers
+    K := int32(3 * dpMinPartSize)
+    v := []int32{5, 7, 9}
+    w := []int32{0, K / 2, K}
+    optimum, _ := knapsackDynamicProgramming(K, v, w)
+    value, _, err := knapsackDynamicProgrammingWorkers(ctx, K, v, w, 4, newMemoryColumnStore())
+    if err != nil || value != optimum || optimum != 14 {
+        t.Error("parallel DP value", value, "dp", optimum, "!= 14", err)
+    }
+}
+
+// Pareto DP is chosen only when its frontier is expected to be small
+// compared to the capacity
+func TestParetoIsFaster(t *testing.T) {
+    for filename, pareto := range map[string]bool{
+        "../data/ks_10000_0": false,
+        "../data/ks_1000_0": false,
+        "../data/ks_400_0": true,
+    } {
+        K, v, w := readTestInstance(t, filename)
+        if paretoIsFaster(K, v, w) != pareto {
+            t.Error(filename, "pareto", !pareto, "!=", pareto)
+        }
+    }
+}
+
+func TestSolve(t *testing.T) {
+    ctx := context.Background()
+    algs := []string{"dp", "dpfile", "pdp", "pareto", "bnb", "dfs", "pbnb", "core",
+                     "heuristic", "auto"}
+    for _, filename := range testFiles[:6] {
+        inst, err := ReadFile(filename)
+        if err != nil {
+            t.Fatal(err)
+        }
+        optimum, _ := knapsackDynamicProgramming(inst.K[0], inst.V, inst.W[0])
+
+        r, err := SolveDP(ctx, inst)
+        if err != nil || !r.Optimal {
+            t.Fatal(filename, "SolveDP", err)
+        }
+        checkResult(t, filename + " SolveDP", inst, r, optimum)
+        r, err = SolveBnB(ctx, inst, Options{})
+        if err != nil || !r.Optimal {
+            t.Fatal(filename, "SolveBnB", err)
+        }
+        checkResult(t, filename + " SolveBnB", inst, r, optimum)
+
+        for _, alg := range algs {
+            r, err := Solve(ctx, inst, alg, Options{Workers: 2, TmpDir: t.TempDir()})
+            if err != nil {
+                t.Fatal(filename, alg, err)
+            }
+            checkResult(t, filename + " " + alg, inst, r, optimum)
+        }
+    }
+}
+
+// the library logs only through opts.Logger
+func TestLogger(t *testing.T) {
+    inst, err := ReadFile("../data/ks_1000_0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    var global, own bytes.Buffer
+    log.SetOutput(&global)
+    defer log.SetOutput(os.Stderr)
+
+    for _, alg := range []string{"core", "dfs"} {
+        if _, err := Solve(context.Background(), inst, alg, Options{LogInterval: 1}); err != nil {
+            t.Fatal(err)
+        }
+        opts := Options{LogInterval: 1, Logger: log.New(&own, "", 0)}
+        if _, err := Solve(context.Background(), inst, alg, opts); err != nil {
+            t.Fatal(err)
+        }
+    }
+    if global.Len() != 0 || own.Len() == 0 {
+        t.Errorf("global log %q, own log %q", global.String(), own.String())
+    }
+}
+
+// canceled search returns the incumbent, canceled DP returns the error
+func TestSolveCanceled(t *testing.T) {
+    inst, err := ReadFile("../data/ks_400_0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+
+    if _, err := SolveDP(ctx, inst); err != context.Canceled {
+        t.Error("SolveDP error", err, "!=", context.Canceled)
+    }
+    for _, alg := range []string{"bnb", "dfs", "pbnb", "core"} {
+        r, err := Solve(ctx, inst, alg, Options{})
+        if err != nil || r.Optimal {
+            t.Error(alg, "canceled search is optimal", r.Optimal, err)
+        }
+        checkResult(t, alg, inst, r, 3967180)
+    }
+
+    // core has no nodes, but stops by the time limit
+    r, err := Solve(context.Background(), inst, "core", Options{TimeLimit: time.Nanosecond})
+    if err != nil || r.Optimal {
+        t.Error("core stopped by time limit is optimal", r.Optimal, err)
+    }
+    checkResult(t, "core", inst, r, 3967180)
+    if _, err := Solve(context.Background(), inst, "core", Options{MaxNodes: 10}); err == nil {
+        t.Error("core accepted node limit")
+    }
+}
+
+// all DP columns of ks_1000_0 without dumping them to disk
+func benchmarkDPColumns(b *testing.B, workers int) {
+    K, v, w, err := readInstance("../data/ks_1000_0")
+    if err != nil {
+        b.Fatal(err)
+    }
+    prev := make([]int32, K+1)
+    next := make([]int32, K+1)
+
+    b.ResetTimer()
+    for i := 0; i < b.N; i++ {
+        for j := 0; j < len(v); j++ {
+            dpNextColumnParallel(prev, next, v[j], w[j], workers)
+            prev, next = next, prev
+        }
+    }
+}
+
+func BenchmarkDPColumnsSerial(b *testing.B) {
+    benchmarkDPColumns(b, 1)
+}
+
+func BenchmarkDPColumnsParallel(b *testing.B) {
+    benchmarkDPColumns(b, runtime.NumCPU())
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package knapsack
+
+import "fmt"
+
+// memory mapped files are only supported on unix
+func createMmapColumnStore(filename string, K int32, N int32) (columnStore, error) {
+    return nil, fmt.Errorf("%s column store is not supported on this platform", STORE_MMAP)
+}
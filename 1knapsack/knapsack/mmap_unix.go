@@ -0,0 +1,71 @@
+//go:build unix
+
+package knapsack
+
+import "fmt"
+import "os"
+import "syscall"
+import "encoding/binary"
+
+// uncompressed columns in the file mapped to memory, so that the OS pages
+// them in and out as needed
+type mmapColumnStore struct {
+    file *os.File
+    data []byte
+    K int32
+    n int32 // stored columns
+}
+
+func createMmapColumnStore(filename string, K int32, N int32) (columnStore, error) {
+    file, err := os.Create(filename)
+    if err != nil {
+        return nil, err
+    }
+    size := int64(N+1) * int64(K+1) * 4
+    if err := file.Truncate(size); err != nil {
+        file.Close()
+        return nil, err
+    }
+    data, err := syscall.Mmap(int(file.Fd()), 0, int(size),
+                              syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+    if err != nil {
+        file.Close()
+        return nil, err
+    }
+    return &mmapColumnStore{file, data, K, 0}, nil
+}
+
+func (s *mmapColumnStore) Len() int32 {
+    return s.n
+}
+
+func (s *mmapColumnStore) Append(column []int32) error {
+    base := int64(s.n) * int64(s.K+1) * 4
+    if base + int64(len(column)) * 4 > int64(len(s.data)) {
+        return fmt.Errorf("all %d columns are stored", s.n)
+    }
+    for k, value := range column {
+        binary.LittleEndian.PutUint32(s.data[base + int64(k)*4:], uint32(value))
+    }
+    s.n++
+    return nil
+}
+
+func (s *mmapColumnStore) Load(j int32, data []int32) error {
+    if j >= s.n {
+        return fmt.Errorf("column %d is not stored", j)
+    }
+    base := int64(j) * int64(s.K+1) * 4
+    for k := range data {
+        data[k] = int32(binary.LittleEndian.Uint32(s.data[base + int64(k)*4:]))
+    }
+    return nil
+}
+
+func (s *mmapColumnStore) Close() error {
+    err := syscall.Munmap(s.data)
+    if cerr := s.file.Close(); err == nil {
+        err = cerr
+    }
+    return err
+}
@@ -0,0 +1,391 @@
+package knapsack
+
+import "fmt"
+import "os"
+import "io"
+import "bufio"
+import "bytes"
+import "encoding/binary"
+import "compress/gzip"
+import "path/filepath"
+
+// DP table storage -----------------------------------------------------------
+
+// pack data with gzip and write it to file, returns the packed size
+func dumpToFile(file io.Writer, data []int32) (int64, error) {
+    var packedBuf bytes.Buffer
+    z := gzip.NewWriter(&packedBuf)
+
+    // write unpacked to packed through gzip
+    if err := binary.Write(z, binary.LittleEndian, data); err != nil {
+        return 0, err
+    }
+    if err := z.Close(); err != nil {
+        return 0, err
+    }
+
+    size := int64(packedBuf.Len())
+    if _, err := packedBuf.WriteTo(file); err != nil {
+        return 0, err
+    }
+    return size, nil
+}
+
+// read size packed bytes from file and unpack them into data
+func loadFromFile(file io.Reader, size int64, data []int32) error {
+    unz, err := gzip.NewReader(io.LimitReader(file, size))
+    if err != nil {
+        return err
+    }
+    defer unz.Close()
+    return binary.Read(unz, binary.LittleEndian, data)
+}
+
+// DP table storage, columns are appended one by one and loaded back for the
+// reconstruction of the solution
+type columnStore interface {
+    // number of stored columns, column j has items 1..j
+    Len() int32
+    Append(column []int32) error
+    // load column j into data
+    Load(j int32, data []int32) error
+    Close() error
+}
+
+const (
+    STORE_MEMORY = "memory"
+    STORE_GZIP = "gzip"
+    STORE_MMAP = "mmap"
+)
+
+// all columns in memory, for small tables only
+type memoryColumnStore struct {
+    columns [][]int32
+}
+
+func newMemoryColumnStore() *memoryColumnStore {
+    return &memoryColumnStore{}
+}
+
+func (s *memoryColumnStore) Len() int32 {
+    return int32(len(s.columns))
+}
+
+func (s *memoryColumnStore) Append(column []int32) error {
+    s.columns = append(s.columns, append([]int32(nil), column...))
+    return nil
+}
+
+func (s *memoryColumnStore) Load(j int32, data []int32) error {
+    if j >= s.Len() {
+        return fmt.Errorf("column %d is not stored", j)
+    }
+    copy(data, s.columns[j])
+    return nil
+}
+
+func (s *memoryColumnStore) Close() error {
+    s.columns = nil
+    return nil
+}
+
+// DP table file: header followed by gzipped columns. The header keeps the
+// instance, so the table is self-contained, and offsets and sizes of the
+// columns written so far, so that an interrupted run is resumed from the
+// last completed column:
+//   magic "KSDP", N, K, number of completed columns (int32)
+//   v, w (N x int32)
+//   offsets, sizes of columns 0..N (N+1 x int64 each)
+const (
+    dpTableFile = "dptable.bin"
+    dpTableMagic = "KSDP"
+)
+
+type gzipColumnStore struct {
+    file *os.File
+    N int32
+    K int32
+    done int32 // completed columns
+    v []int32
+    w []int32
+    offsets []int64
+    sizes []int64
+}
+
+func dpTableHeaderSize(N int32) int64 {
+    return int64(len(dpTableMagic)) + 3*4 + int64(N)*2*4 + int64(N+1)*2*8
+}
+
+// position of the completed columns counter in the header
+func (t *gzipColumnStore) donePosition() int64 {
+    return int64(len(dpTableMagic)) + 2*4
+}
+
+// positions of the offset and size of column j in the header
+func (t *gzipColumnStore) columnPositions(j int32) (int64, int64) {
+    offsets := int64(len(dpTableMagic)) + 3*4 + int64(t.N)*2*4
+    sizes := offsets + int64(t.N+1)*8
+    return offsets + int64(j)*8, sizes + int64(j)*8
+}
+
+// end of the last completed column, the next one is written there
+func (t *gzipColumnStore) end() int64 {
+    if t.done == 0 {
+        return dpTableHeaderSize(t.N)
+    }
+    return t.offsets[t.done-1] + t.sizes[t.done-1]
+}
+
+func writeAt(file *os.File, position int64, data interface{}) error {
+    var buf bytes.Buffer
+    if err := binary.Write(&buf, binary.LittleEndian, data); err != nil {
+        return err
+    }
+    _, err := file.WriteAt(buf.Bytes(), position)
+    return err
+}
+
+// create a new table file with no completed columns
+func createGzipColumnStore(filename string, K int32, v []int32, w []int32) (*gzipColumnStore, error) {
+    file, err := os.Create(filename)
+    if err != nil {
+        return nil, err
+    }
+    N := int32(len(v))
+    t := &gzipColumnStore{file, N, K, 0, v, w, make([]int64, N+1), make([]int64, N+1)}
+
+    var buf bytes.Buffer
+    buf.WriteString(dpTableMagic)
+    for _, data := range []interface{}{N, K, t.done, v, w, t.offsets, t.sizes} {
+        if err := binary.Write(&buf, binary.LittleEndian, data); err != nil {
+            file.Close()
+            return nil, err
+        }
+    }
+    if _, err := buf.WriteTo(file); err != nil {
+        file.Close()
+        return nil, err
+    }
+    return t, nil
+}
+
+// open the table file written by createGzipColumnStore, possibly incomplete
+func openGzipColumnStore(filename string) (*gzipColumnStore, error) {
+    file, err := os.OpenFile(filename, os.O_RDWR, 0)
+    if err != nil {
+        return nil, err
+    }
+    t, err := readDPTableHeader(file)
+    if err != nil {
+        file.Close()
+        return nil, fmt.Errorf("%s: %v", filename, err)
+    }
+    return t, nil
+}
+
+func readDPTableHeader(file *os.File) (*gzipColumnStore, error) {
+    r := bufio.NewReader(file)
+    magic := make([]byte, len(dpTableMagic))
+    if _, err := io.ReadFull(r, magic); err != nil || string(magic) != dpTableMagic {
+        return nil, fmt.Errorf("not a DP table file")
+    }
+    t := &gzipColumnStore{file: file}
+    for _, data := range []interface{}{&t.N, &t.K, &t.done} {
+        if err := binary.Read(r, binary.LittleEndian, data); err != nil {
+            return nil, fmt.Errorf("truncated header: %v", err)
+        }
+    }
+    if t.N < 0 || t.K < 0 || t.done < 0 || t.done > t.N+1 {
+        return nil, fmt.Errorf("invalid header: N %d, K %d, %d columns completed",
+                               t.N, t.K, t.done)
+    }
+    t.v, t.w = make([]int32, t.N), make([]int32, t.N)
+    t.offsets, t.sizes = make([]int64, t.N+1), make([]int64, t.N+1)
+    for _, data := range []interface{}{t.v, t.w, t.offsets, t.sizes} {
+        if err := binary.Read(r, binary.LittleEndian, data); err != nil {
+            return nil, fmt.Errorf("truncated header: %v", err)
+        }
+    }
+
+    info, err := file.Stat()
+    if err != nil {
+        return nil, err
+    }
+    if t.end() > info.Size() {
+        return nil, fmt.Errorf("%d columns completed, but the file is truncated", t.done)
+    }
+    return t, nil
+}
+
+// table is for the same instance
+func (t *gzipColumnStore) matches(K int32, v []int32, w []int32) bool {
+    if t.K != K || int(t.N) != len(v) {
+        return false
+    }
+    for j := range v {
+        if t.v[j] != v[j] || t.w[j] != w[j] {
+            return false
+        }
+    }
+    return true
+}
+
+func (t *gzipColumnStore) Len() int32 {
+    return t.done
+}
+
+// append the next column: the data is written after the last completed
+// column (anything there is left from the interrupted write), then its
+// position, and only then it is counted as completed
+func (t *gzipColumnStore) Append(column []int32) error {
+    if t.done > t.N {
+        return fmt.Errorf("all %d columns are completed", t.done)
+    }
+    position := t.end()
+    if _, err := t.file.Seek(position, 0); err != nil {
+        return err
+    }
+    if err := t.file.Truncate(position); err != nil {
+        return err
+    }
+    size, err := dumpToFile(t.file, column)
+    if err != nil {
+        return err
+    }
+
+    j := t.done
+    offsetPosition, sizePosition := t.columnPositions(j)
+    if err := writeAt(t.file, offsetPosition, position); err != nil {
+        return err
+    }
+    if err := writeAt(t.file, sizePosition, size); err != nil {
+        return err
+    }
+    if err := writeAt(t.file, t.donePosition(), j+1); err != nil {
+        return err
+    }
+    t.offsets[j], t.sizes[j] = position, size
+    t.done++
+    return nil
+}
+
+func (t *gzipColumnStore) Load(j int32, data []int32) error {
+    if j >= t.done {
+        return fmt.Errorf("column %d is not completed", j)
+    }
+    if _, err := t.file.Seek(t.offsets[j], 0); err != nil {
+        return err
+    }
+    if err := loadFromFile(t.file, t.sizes[j], data); err != nil {
+        return fmt.Errorf("column %d: %v", j, err)
+    }
+    return nil
+}
+
+func (t *gzipColumnStore) Close() error {
+    return t.file.Close()
+}
+
+// open the table for the instance to resume the computation, or create a
+// new one if there is no table or it is for another instance
+func resumeGzipColumnStore(filename string, K int32, v []int32, w []int32,
+                           opts Options) (*gzipColumnStore, error) {
+    t, err := openGzipColumnStore(filename)
+    if err == nil {
+        if t.matches(K, v, w) {
+            if t.done > 0 {
+                opts.logln("resuming DP from", filename, "with", t.done, "of",
+                           t.N+1, "columns completed")
+            }
+            return t, nil
+        }
+        t.Close()
+    } else if !os.IsNotExist(err) {
+        opts.logln("ignoring DP table:", err)
+    }
+    return createGzipColumnStore(filename, K, v, w)
+}
+
+// the table file is only kept by the gzip store, and it is not temporary,
+// so the temporary directory is not used with it
+func checkStoreOptions(opts Options) error {
+    switch opts.Store {
+    case STORE_MEMORY, STORE_GZIP, STORE_MMAP, "":
+    default:
+        return fmt.Errorf("unknown column store %q", opts.Store)
+    }
+    if opts.Table == "" {
+        return nil
+    }
+    if opts.Store != STORE_GZIP && opts.Store != "" {
+        return fmt.Errorf("table file %s needs %s column store, not %s",
+                          opts.Table, STORE_GZIP, opts.Store)
+    }
+    if opts.TmpDir != "" {
+        return fmt.Errorf("table file %s is kept, temporary directory %s is not used",
+                          opts.Table, opts.TmpDir)
+    }
+    return nil
+}
+
+// store of the given kind; the files are created in dir, except for the
+// table file given in opts, which is resumed and kept after the run
+func openColumnStore(opts Options, dir string,
+                     K int32, v []int32, w []int32) (columnStore, error) {
+    if err := checkStoreOptions(opts); err != nil {
+        return nil, err
+    }
+    switch opts.Store {
+    case STORE_MEMORY:
+        return newMemoryColumnStore(), nil
+    case STORE_MMAP:
+        return createMmapColumnStore(filepath.Join(dir, "dptable.raw"), K, int32(len(v)))
+    case STORE_GZIP, "":
+        filename := opts.Table
+        if filename == "" {
+            filename = filepath.Join(dir, dpTableFile)
+        }
+        return resumeGzipColumnStore(filename, K, v, w, opts)
+    }
+    return nil, fmt.Errorf("unknown column store %q", opts.Store)
+}
+
+// restore optimal value and the best set of items from the complete table
+func reconstruct(store columnStore, K int32, w []int32) (int32, []byte, error) {
+    N := int32(len(w))
+    if store.Len() != N+1 {
+        return 0, nil, fmt.Errorf("%d of %d columns completed", store.Len(), N+1)
+    }
+    var O = [][]int32{make([]int32, K+1), make([]int32, K+1)}
+    var x = make([]byte, N)
+
+    k := K
+    if err := store.Load(N, O[1]); err != nil {
+        return 0, nil, err
+    }
+    value := O[1][k]
+    for i := N; i > 0; i-- {
+        // preload first (previous) column
+        if err := store.Load(i-1, O[0]); err != nil {
+            return 0, nil, err
+        }
+        if O[1][k] != O[0][k] {
+            x[i-1] = 1
+            k -= w[i-1]
+        }
+        // previous column becomes current one
+        O[0], O[1] = O[1], O[0]
+    }
+    return value, x, nil
+}
+
+// restore the solution from the completed table file, nothing but the file
+// is needed
+func reconstructFromFile(filename string) (int32, []byte, error) {
+    t, err := openGzipColumnStore(filename)
+    if err != nil {
+        return 0, nil, err
+    }
+    defer t.Close()
+    return reconstruct(t, t.K, t.w)
+}
@@ -0,0 +1,578 @@
+package knapsack
+
+import "context"
+import "sort"
+
+// Multi-dimensional knapsack -------------------------------------------------
+
+// items are ordered by value per weight in a single dimension, so the
+// bound is calculated over each dimension in its own order
+type byValuePerWeightIn struct {
+    order []int32   // item positions
+    v []int32
+    w []int32       // weights in the dimension
+}
+
+func (self byValuePerWeightIn) Len() int { return len(self.order) }
+func (self byValuePerWeightIn) Less(i, j int) bool {
+    a := int64(self.v[self.order[i]]) * int64(self.w[self.order[j]])
+    b := int64(self.v[self.order[j]]) * int64(self.w[self.order[i]])
+    return a > b
+}
+func (self byValuePerWeightIn) Swap(i, j int) { self.order[i], self.order[j] = self.order[j], self.order[i] }
+
+// depth-first B&B state, items are decided in the input order
+type multiDimContext struct {
+    K []int32      // capacity in each dimension
+    v []int32
+    w [][]int32    // w[d][i] -- weight of item i in dimension d
+    order [][]int32 // order[d] -- items sorted by value per weight in dimension d
+    used []int64   // used capacity in each dimension on the current path
+    x []byte       // currently selected items
+    bestset []byte // best selected items
+    maxvalue int32
+    monitor *searchMonitor
+    stopped bool
+}
+
+// minimum of the fractional bounds of each single dimension: the
+// solution must fit all of them, so each one is a valid relaxation
+func (self *node) estimateMultiDim(c *multiDimContext) int64 {
+    result := int64(-1)
+    for d := 0; d < len(c.K); d++ {
+        C := int64(c.K[d]) - c.used[d]
+        bound := int64(self.value)
+        for _, i := range c.order[d] {
+            // only undecided items
+            if i <= self.index {
+                continue
+            }
+            if int64(c.w[d][i]) <= C {
+                C -= int64(c.w[d][i])
+                bound += int64(c.v[i])
+            } else {
+                bound += C * int64(c.v[i]) / int64(c.w[d][i])
+                break
+            }
+        }
+        if result == -1 || bound < result {
+            result = bound
+        }
+    }
+    return result
+}
+
+func (c *multiDimContext) fits(i int32) bool {
+    for d := 0; d < len(c.K); d++ {
+        if c.used[d] + int64(c.w[d][i]) > int64(c.K[d]) {
+            return false
+        }
+    }
+    return true
+}
+
+func (c *multiDimContext) use(i int32, sign int64) {
+    for d := 0; d < len(c.K); d++ {
+        c.used[d] += sign * int64(c.w[d][i])
+    }
+}
+
+func (c *multiDimContext) search(v *node) {
+    if c.monitor.stop(c.maxvalue, func() int64 { return v.bound }) {
+        c.stopped = true
+        return
+    }
+    c.monitor.nodes++
+
+    next := v.index + 1
+    if next >= int32(len(c.v)) {
+        return
+    }
+
+    if c.fits(next) {
+        c.use(next, 1)
+        c.x[next] = 1
+        in := node{next, v.value + c.v[next], 0, 0, 0, nil}
+        if in.value > c.maxvalue {
+            c.maxvalue = in.value
+            copy(c.bestset, c.x)
+        }
+        in.bound = in.estimateMultiDim(c)
+        if in.bound > int64(c.maxvalue) {
+            c.search(&in)
+        }
+        // undo
+        c.x[next] = 0
+        c.use(next, -1)
+        if c.stopped {
+            return
+        }
+    }
+
+    out := node{next, v.value, 0, 0, 0, nil}
+    out.bound = out.estimateMultiDim(c)
+    if out.bound > int64(c.maxvalue) {
+        c.search(&out)
+    }
+}
+
+// K -- capacities, w[d][i] -- weight of item i in dimension d
+// returns best value, selected items and whether the value is optimal
+func knapsackMultiDim(K []int32, v []int32, w [][]int32,
+                      opts Options) (int32, []byte, bool) {
+    N := len(v)
+    M := len(K)
+    c := multiDimContext{K, v, w,
+                         make([][]int32, M),
+                         make([]int64, M),
+                         make([]byte, N), make([]byte, N),
+                         0,
+                         newSearchMonitor(opts),
+                         false}
+    for d := 0; d < M; d++ {
+        c.order[d] = make([]int32, N)
+        for i := 0; i < N; i++ {
+            c.order[d][i] = int32(i)
+        }
+        sort.Sort(byValuePerWeightIn{c.order[d], v, w[d]})
+    }
+
+    // index = -1, start with fake root node
+    root := node{-1, 0, 0, 0, 0, nil}
+    root.bound = root.estimateMultiDim(&c)
+    c.search(&root)
+
+    bound := int64(c.maxvalue)
+    if c.stopped {
+        bound = root.bound
+    }
+    c.monitor.done(c.maxvalue, bound)
+    return c.maxvalue, c.bestset, !c.stopped
+}
+
+// Multiple-choice knapsack ---------------------------------------------------
+
+// step from one item of the group to the next one on the upper convex hull
+// of (weight, value) points of the group
+type groupIncrement struct {
+    group int32
+    dw int64
+    dv int64
+}
+
+type byEfficiency []groupIncrement
+func (self byEfficiency) Len() int { return len(self) }
+func (self byEfficiency) Less(i, j int) bool {
+    return self[i].dv * self[j].dw > self[j].dv * self[i].dw
+}
+func (self byEfficiency) Swap(i, j int) { self[i], self[j] = self[j], self[i] }
+
+// sort group items by weight
+type byWeightIn struct {
+    items []int32
+    v []int32
+    w []int32
+}
+
+func (self byWeightIn) Len() int { return len(self.items) }
+func (self byWeightIn) Less(i, j int) bool {
+    a, b := self.items[i], self.items[j]
+    if self.w[a] != self.w[b] {
+        return self.w[a] < self.w[b]
+    }
+    return self.v[a] > self.v[b]
+}
+func (self byWeightIn) Swap(i, j int) { self.items[i], self.items[j] = self.items[j], self.items[i] }
+
+// depth-first B&B state, groups are decided one by one, exactly one item of
+// each group is selected
+type groupedContext struct {
+    K int32
+    v []int32
+    w []int32
+    groups [][]int32        // items of each group
+    lightest []int32        // lightest item of each group (LP starts with it)
+    increments []groupIncrement // hull increments of all groups by efficiency
+    x []byte                // currently selected items
+    bestset []byte          // best selected items
+    maxvalue int32          // -1 until a feasible solution is found
+    monitor *searchMonitor
+    stopped bool
+}
+
+// LP relaxation of the multiple-choice knapsack for the undecided groups:
+// take the lightest item of each group and then increments along the
+// convex hulls in the order of decreasing efficiency; -1 if even the
+// lightest items do not fit
+func (self *node) estimateGrouped(c *groupedContext) int64 {
+    C := int64(c.K) - int64(self.weight)
+    result := int64(self.value)
+    for g := self.index + 1; g < int32(len(c.groups)); g++ {
+        C -= int64(c.w[c.lightest[g]])
+        result += int64(c.v[c.lightest[g]])
+    }
+    if C < 0 {
+        return -1
+    }
+    for _, inc := range c.increments {
+        if inc.group <= self.index {
+            continue
+        }
+        if inc.dw <= C {
+            C -= inc.dw
+            result += inc.dv
+        } else {
+            result += C * inc.dv / inc.dw
+            break
+        }
+    }
+    return result
+}
+
+func (c *groupedContext) search(v *node) {
+    if c.monitor.stop(c.maxvalue, func() int64 { return v.bound }) {
+        c.stopped = true
+        return
+    }
+    c.monitor.nodes++
+
+    g := v.index + 1
+    if g >= int32(len(c.groups)) {
+        // all groups are decided
+        if v.value > c.maxvalue {
+            c.maxvalue = v.value
+            copy(c.bestset, c.x)
+        }
+        return
+    }
+
+    for _, i := range c.groups[g] {
+        child := node{g, v.value + c.v[i], v.weight + c.w[i], 0, 0, nil}
+        if child.weight > c.K {
+            continue
+        }
+        c.x[i] = 1
+        child.bound = child.estimateGrouped(c)
+        if child.bound > int64(c.maxvalue) {
+            c.search(&child)
+        }
+        // undo
+        c.x[i] = 0
+        if c.stopped {
+            return
+        }
+    }
+}
+
+// upper convex hull of the group items (sorted by weight), returns hull
+// items; dominated items (heavier but not more valuable) are dropped
+func groupHull(items []int32, v []int32, w []int32) []int32 {
+    hull := make([]int32, 0, len(items))
+    for _, i := range items {
+        n := len(hull)
+        if n > 0 && v[i] <= v[hull[n-1]] {
+            continue
+        }
+        // drop the last point while it is below the line to the new one
+        for n >= 2 {
+            a, b := hull[n-2], hull[n-1]
+            // slope(a, b) <= slope(a, i)
+            if (int64(v[b]) - int64(v[a])) * (int64(w[i]) - int64(w[a])) <=
+                (int64(v[i]) - int64(v[a])) * (int64(w[b]) - int64(w[a])) {
+                hull = hull[:n-1]
+                n--
+            } else {
+                break
+            }
+        }
+        hull = append(hull, i)
+    }
+    return hull
+}
+
+// group[i] -- group of item i (groups are numbered from 0)
+// returns best value (-1 if infeasible), selected items and whether the
+// value is optimal
+func knapsackGrouped(K int32, v []int32, w []int32, group []int32,
+                     opts Options) (int32, []byte, bool) {
+    N := len(v)
+    var G int32
+    for i := 0; i < N; i++ {
+        if group[i] + 1 > G {
+            G = group[i] + 1
+        }
+    }
+
+    c := groupedContext{K, v, w,
+                        make([][]int32, G),
+                        make([]int32, G),
+                        make([]groupIncrement, 0),
+                        make([]byte, N), make([]byte, N),
+                        -1,
+                        newSearchMonitor(opts),
+                        false}
+    for i := 0; i < N; i++ {
+        c.groups[group[i]] = append(c.groups[group[i]], int32(i))
+    }
+    for g := int32(0); g < G; g++ {
+        if len(c.groups[g]) == 0 {
+            opts.logln("group", g, "is empty")
+            return -1, c.bestset, true
+        }
+        sort.Sort(byWeightIn{c.groups[g], v, w})
+        hull := groupHull(c.groups[g], v, w)
+        c.lightest[g] = hull[0]
+        for j := 1; j < len(hull); j++ {
+            c.increments = append(c.increments,
+                groupIncrement{g,
+                               int64(w[hull[j]]) - int64(w[hull[j-1]]),
+                               int64(v[hull[j]]) - int64(v[hull[j-1]])})
+        }
+        // try heavier (usually more valuable) items first
+        sort.Sort(sort.Reverse(byWeightIn{c.groups[g], v, w}))
+    }
+    sort.Sort(byEfficiency(c.increments))
+
+    // index = -1, start with fake root node
+    root := node{-1, 0, 0, 0, 0, nil}
+    root.bound = root.estimateGrouped(&c)
+    if root.bound >= 0 {
+        c.search(&root)
+    }
+
+    bound := int64(c.maxvalue)
+    if c.stopped {
+        bound = root.bound
+    }
+    c.monitor.done(c.maxvalue, bound)
+    return c.maxvalue, c.bestset, !c.stopped
+}
+
+// Bounded and unbounded knapsack ---------------------------------------------
+
+// split item with count copies into 0/1 items of 1, 2, 4, ..., rest copies,
+// any number of copies 0..count is a sum of some of them; the DP recurrence
+// for multiplicities is then the usual 0/1 one over the pieces
+func splitCopies(count int32) []int32 {
+    pieces := make([]int32, 0)
+    for m := int32(1); count > 0; m *= 2 {
+        if m > count {
+            m = count
+        }
+        pieces = append(pieces, m)
+        count -= m
+    }
+    return pieces
+}
+
+// returns optimal value and number of copies of each item
+func knapsackBoundedDP(ctx context.Context, K int32, v []int32, w []int32,
+                       count []int32) (int32, []int32, error) {
+    pv := make([]int32, 0)
+    pw := make([]int32, 0)
+    item := make([]int32, 0)   // item of each piece
+    copies := make([]int32, 0) // copies in each piece
+    for i := 0; i < len(v); i++ {
+        for _, m := range splitCopies(count[i]) {
+            // pieces heavier than the knapsack are useless
+            if int64(m) * int64(w[i]) > int64(K) {
+                break
+            }
+            pv = append(pv, m * v[i])
+            pw = append(pw, m * w[i])
+            item = append(item, int32(i))
+            copies = append(copies, m)
+        }
+    }
+
+    value, x, err := knapsackDivideAndConquer(ctx, K, pv, pw)
+    if err != nil {
+        return 0, nil, err
+    }
+    result := make([]int32, len(v))
+    for j := 0; j < len(x); j++ {
+        if x[j] == 1 {
+            result[item[j]] += copies[j]
+        }
+    }
+    return value, result, nil
+}
+
+// depth-first B&B state, items are sorted by value per weight and a node
+// decides how many copies of the next item to take
+type boundedContext struct {
+    K int32
+    items itemList
+    count []int32  // available copies of each item
+    x []int32      // currently selected copies
+    best []int32   // best selected copies
+    maxvalue int32
+    monitor *searchMonitor
+    stopped bool
+}
+
+// fractional bound where all copies of an item are considered together
+func (self *node) estimateBounded(c *boundedContext) int64 {
+    C := int64(c.K) - int64(self.weight)
+    result := int64(self.value)
+    for j := self.index + 1; j < int32(len(c.items)); j++ {
+        weight := int64(c.count[j]) * int64(c.items[j].weight)
+        if weight <= C {
+            C -= weight
+            result += int64(c.count[j]) * int64(c.items[j].value)
+        } else {
+            result += C * int64(c.items[j].value) / int64(c.items[j].weight)
+            break
+        }
+    }
+    return result
+}
+
+func (c *boundedContext) search(v *node) {
+    if c.monitor.stop(c.maxvalue, func() int64 { return v.bound }) {
+        c.stopped = true
+        return
+    }
+    c.monitor.nodes++
+
+    next := v.index + 1
+    if next >= int32(len(c.items)) {
+        return
+    }
+
+    item := c.items[next]
+    copies := c.count[next]
+    if item.weight > 0 && (c.K - v.weight) / item.weight < copies {
+        copies = (c.K - v.weight) / item.weight
+    }
+
+    // take as many copies as possible first; with fewer copies the freed
+    // capacity goes to items of no better value per weight, so the bound
+    // only decreases and the rest of copies are pruned as soon as it can't
+    // beat the incumbent
+    for m := copies; m >= 0; m-- {
+        child := node{next, v.value + m * item.value,
+                      v.weight + m * item.weight, 0, 0, nil}
+        c.x[next] = m
+        if child.value > c.maxvalue {
+            c.maxvalue = child.value
+            copy(c.best, c.x)
+        }
+        child.bound = child.estimateBounded(c)
+        if child.bound <= int64(c.maxvalue) {
+            break
+        }
+        c.search(&child)
+        if c.stopped {
+            break
+        }
+    }
+    // undo
+    c.x[next] = 0
+}
+
+// returns best value, number of copies of each item and whether the value
+// is optimal
+func knapsackBounded(K int32, v []int32, w []int32, count []int32,
+                     opts Options) (int32, []int32, bool) {
+    N := len(v)
+    items := make([]node, N)
+    for i := 0; i < N; i++ {
+        items[i] = node{int32(i), v[i], w[i], -1, 0, nil}
+    }
+    sort.Sort(byValuePerWeight(items))
+
+    c := boundedContext{K, items,
+                        make([]int32, N),
+                        make([]int32, N), make([]int32, N),
+                        0,
+                        newSearchMonitor(opts),
+                        false}
+    for j := 0; j < N; j++ {
+        c.count[j] = count[items[j].index]
+    }
+
+    // index = -1, start with fake root node
+    root := node{-1, 0, 0, 0, 0, nil}
+    root.bound = root.estimateBounded(&c)
+    c.search(&root)
+
+    bound := int64(c.maxvalue)
+    if c.stopped {
+        bound = root.bound
+    }
+    c.monitor.done(c.maxvalue, bound)
+
+    // restore indexes
+    result := make([]int32, N)
+    for j := 0; j < N; j++ {
+        result[items[j].index] = c.best[j]
+    }
+    return c.maxvalue, result, !c.stopped
+}
+
+// Knapsack with conflicts -----------------------------------------------------
+
+// item i is in conflict with some already selected item (at most the node
+// index, the later ones are not decided yet)
+func (self *node) forbidden(i int32, conflicts [][]int32) bool {
+    if conflicts == nil {
+        return false
+    }
+    for _, j := range conflicts[i] {
+        if j <= self.index && self.sel[j] == 1 {
+            return true
+        }
+    }
+    return false
+}
+
+// bound of the node with conflicts: items in conflict with the selected ones
+// can't be taken, so they are skipped in the Dantzig bound; U2 bound, which
+// ignores conflicts, is valid too, so the smaller one is used
+func (self *node) estimateConflicts(K int32, N int32, items itemList,
+                                    conflicts [][]int32) int64 {
+    u2 := self.estimate(K, N, items)
+    if conflicts == nil || self.weight > K {
+        return u2
+    }
+
+    result := int64(self.value)
+    totweight := int64(self.weight)
+    for j := self.index + 1; j < N; j++ {
+        if self.forbidden(j, conflicts) {
+            continue
+        }
+        if totweight + int64(items[j].weight) > int64(K) {
+            result += (int64(K) - totweight) * int64(items[j].value) / int64(items[j].weight)
+            break
+        }
+        totweight += int64(items[j].weight)
+        result += int64(items[j].value)
+    }
+
+    if result < u2 {
+        return result
+    }
+    return u2
+}
+
+// best-first B&B for the instance with conflicts given by pairs of items in
+// the input order
+func conflictBranchAndBound(edges [][2]int32) branchAndBoundFunc {
+    return func(K int32, items itemList, opts Options,
+                maxvalue *int32, bound *int64) ([]byte, bool) {
+        // conflicts between positions of sorted items
+        position := make([]int32, len(items))
+        for i := range items {
+            position[items[i].index] = int32(i)
+        }
+        conflicts := make([][]int32, len(items))
+        for _, e := range edges {
+            i, j := position[e[0]], position[e[1]]
+            conflicts[i] = append(conflicts[i], j)
+            conflicts[j] = append(conflicts[j], i)
+        }
+        return knapsackBranchAndBoundConflicts(K, items, conflicts, opts, maxvalue, bound)
+    }
+}
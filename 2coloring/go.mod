@@ -0,0 +1,3 @@
+module discrete-optimization-001/2coloring
+
+go 1.19
@@ -4,6 +4,11 @@ import "strconv"
 import "sort"
 import "fmt"
 import "os"
+import "log"
+import "io"
+import "time"
+import "flag"
+import "math/rand"
 import "container/list"
 
 // functions which Go developers should have implemented but happened
@@ -64,6 +69,9 @@ type CSPContext struct {
     currentUnassignedVertex int // current vertex in recursive solution calls
     varHeuristic VarHeuristic
     valHeuristic ValHeuristic
+    deadline time.Time // stop the search at this time (zero -- no limit)
+    nodes int64        // number of solve calls
+    stopped bool       // search was stopped by the deadline
 }
 
 // save vertex order without reordering graph vertices
@@ -144,21 +152,20 @@ func (g *Graph) vertexNeighborColors(i int32) []int32 {
     return neibColors
 }
 
+// min color (starting from 1) not in colors, 0 is no color
 func minUnusedColor(colors *[]int32) int32 {
     sort.Sort(ByInt32(*colors))
     //fmt.Println(*colors)
 
-    if len(*colors) == 1 {
-        return (*colors)[0] + 1
-    }
-
-    for i := 0; i < len(*colors) - 1; i++ {
-        if (*colors)[i+1] - (*colors)[i] > 1 {
-            return (*colors)[i] + 1
+    var color int32 = 1
+    for i := 0; i < len(*colors); i++ {
+        if (*colors)[i] == color {
+            color += 1
+        } else if (*colors)[i] > color {
+            break
         }
     }
-
-    return (*colors)[len(*colors) - 1] + 1
+    return color
 }
 
 func (g *Graph) assignVertexColor(i int32) {
@@ -181,13 +188,49 @@ func (g *Graph) printColors() {
     fmt.Printf("\n")
 }
 
-func (g *Graph) printSolution() {
-    fmt.Println(g.chromaticNumber(), 0)
+// prints 1 if the number of colors is proven to be optimal, 0 otherwise
+func optimalFlag(optimal bool) int {
+    if optimal {
+        return 1
+    }
+    return 0
+}
+
+func (g *Graph) printSolution(optimal bool) {
+    fmt.Println(g.chromaticNumber(), optimalFlag(optimal))
     g.printColors()
 }
 
+// save colors of all vertices
+func (g *Graph) saveColors() []int32 {
+    colors := make([]int32, g.NV())
+    for i := 0; i < g.NV(); i++ {
+        colors[i] = g.V[i].color
+    }
+    return colors
+}
+
+func (g *Graph) restoreColors(colors []int32) {
+    for i := 0; i < g.NV(); i++ {
+        g.V[i].color = colors[i]
+    }
+}
+
+func (g *Graph) resetColors() {
+    for i := 0; i < g.NV(); i++ {
+        g.V[i].color = 0
+    }
+}
+
 // greedy approach
 func (g *Graph) solveGreedySimple() {
+    g.colorGreedy()
+    g.printSolution(g.chromaticNumber() <= g.greedyLowerBound())
+}
+
+// color vertices in the order of decreasing degree with the min color
+// not used by neighbors
+func (g *Graph) colorGreedy() {
     //NE := len(g.E)
     NV := len(g.V)
     //D := degree(&g)
@@ -214,7 +257,713 @@ func (g *Graph) solveGreedySimple() {
 
     //fmt.Println(g.chromaticNumber(), 0)
     //g.printColors()
-    g.printSolution()
+}
+
+//
+// DSATUR
+//
+
+// adjacency lists
+func (g *Graph) neighbors() [][]int32 {
+    adj := make([][]int32, g.NV())
+    for i := 0; i < g.NV(); i++ {
+        adj[i] = make([]int32, len(g.V[i].E))
+        for j := 0; j < len(g.V[i].E); j++ {
+            adj[i][j] = g.otherVertex(int32(i), int32(j))
+        }
+    }
+    return adj
+}
+
+// state of DSATUR coloring, colors are assigned and removed incrementally
+type DSaturContext struct {
+    adj [][]int32
+    colors []int32   // vertex colors, 0 -- uncolored
+    count [][]int32  // count[v][c] -- number of neighbors of v with color c
+    saturation []int32 // number of different colors of neighbors
+    degree []int32   // number of uncolored neighbors
+    uncolored int    // number of uncolored vertices
+    best []int32     // best complete coloring found
+    bestColors int32 // number of colors of the best coloring
+    lower int32      // the search stops when the coloring reaches it
+    deadline time.Time // stop the search at this time (zero -- no limit)
+    nodes int64
+    stopped bool
+}
+
+func (g *Graph) newDSaturContext() *DSaturContext {
+    NV := g.NV()
+    c := DSaturContext{g.neighbors(), make([]int32, NV), make([][]int32, NV),
+                       make([]int32, NV), make([]int32, NV), NV, nil, 0, 0,
+                       time.Time{}, 0, false}
+    for i := 0; i < NV; i++ {
+        // a vertex never has more than NV colors around it
+        c.count[i] = make([]int32, NV + 2)
+        c.degree[i] = int32(len(c.adj[i]))
+    }
+    return &c
+}
+
+func (c *DSaturContext) assign(v int32, color int32) {
+    c.colors[v] = color
+    c.uncolored--
+    for _, u := range c.adj[v] {
+        if c.count[u][color] == 0 {
+            c.saturation[u]++
+        }
+        c.count[u][color]++
+        c.degree[u]--
+    }
+}
+
+func (c *DSaturContext) unassign(v int32) {
+    color := c.colors[v]
+    c.colors[v] = 0
+    c.uncolored++
+    for _, u := range c.adj[v] {
+        c.count[u][color]--
+        if c.count[u][color] == 0 {
+            c.saturation[u]--
+        }
+        c.degree[u]++
+    }
+}
+
+// uncolored vertex with the max saturation, ties are broken by the max
+// degree in the uncolored subgraph
+func (c *DSaturContext) selectVertex() int32 {
+    var best int32 = -1
+    for v := int32(0); v < int32(len(c.colors)); v++ {
+        if c.colors[v] != 0 {
+            continue
+        }
+        if best == -1 || c.saturation[v] > c.saturation[best] ||
+           (c.saturation[v] == c.saturation[best] && c.degree[v] > c.degree[best]) {
+            best = v
+        }
+    }
+    return best
+}
+
+// min color not used by neighbors of v
+func (c *DSaturContext) minColor(v int32) int32 {
+    var color int32 = 1
+    for c.count[v][color] > 0 {
+        color++
+    }
+    return color
+}
+
+// DSATUR greedy: color the most saturated vertex with the min color not
+// used by its neighbors
+func (g *Graph) colorDSatur() {
+    c := g.newDSaturContext()
+    for c.uncolored > 0 {
+        v := c.selectVertex()
+        c.assign(v, c.minColor(v))
+    }
+    g.restoreColors(c.colors)
+}
+
+// branch on colors of the most saturated vertex: the colors used so far
+// and one new color while the coloring stays better than the best one
+func (c *DSaturContext) search(used int32) {
+    c.nodes++
+    if !c.deadline.IsZero() && c.nodes % 1024 == 0 && time.Now().After(c.deadline) {
+        c.stopped = true
+    }
+    if c.stopped {
+        return
+    }
+
+    if c.uncolored == 0 {
+        copy(c.best, c.colors)
+        c.bestColors = used
+        log.Println("DSATUR found coloring with", used, "colors")
+        return
+    }
+
+    v := c.selectVertex()
+    for color := int32(1); color <= used + 1 && color < c.bestColors; color++ {
+        if c.count[v][color] > 0 {
+            continue
+        }
+        c.assign(v, color)
+        c.search(max(used, color))
+        c.unassign(v)
+        if c.bestColors <= c.lower || c.stopped {
+            return
+        }
+    }
+}
+
+// exact DSATUR branch and bound: starts from DSATUR greedy coloring with
+// vertices of the max clique precolored and stops at the clique size;
+// returns if the coloring is proven optimal within the time budget
+// (0 -- no limit), the best coloring found is left in the graph
+func (g *Graph) dsaturBranchAndBound(budget time.Duration) bool {
+    var deadline time.Time
+    if budget > 0 {
+        deadline = time.Now().Add(budget)
+    }
+
+    clique, _ := g.maxClique(cliqueBudget(budget))
+    g.colorDSatur()
+
+    c := g.newDSaturContext()
+    c.best = g.saveColors()
+    c.bestColors = g.chromaticNumber()
+    c.lower = int32(len(clique))
+    c.deadline = deadline
+    log.Println("DSATUR coloring with", c.bestColors, "colors, lower bound", c.lower)
+
+    // clique vertices get different colors anyway, fixing them removes
+    // symmetric colorings
+    for i, v := range clique {
+        c.assign(v, int32(i + 1))
+    }
+    if c.bestColors > c.lower {
+        c.search(c.lower)
+    }
+
+    g.restoreColors(c.best)
+    return !c.stopped
+}
+
+func (g *Graph) solveDSatur() {
+    g.colorDSatur()
+    g.printSolution(g.chromaticNumber() <= g.greedyLowerBound())
+}
+
+func (g *Graph) solveDSaturBranchAndBound(budget time.Duration) {
+    optimal := g.dsaturBranchAndBound(budget)
+    g.printSolution(optimal)
+}
+
+//
+// Tabu search
+//
+
+const (
+    TABU_MAX_STALL = 1000000 // iterations without improvement before giving up
+    TABU_TENURE_RANDOM = 10  // tabu tenure is random(10) + 0.6 * conflicting vertices
+    TABU_TENURE_FACTOR = 0.6
+)
+
+// state of Tabucol min conflicts search for a coloring with k colors,
+// conflict tables are updated incrementally on each move
+type TabuContext struct {
+    adj [][]int32
+    k int32
+    colors []int32  // vertex colors from 1 to k
+    gamma [][]int32 // gamma[v][c] -- number of neighbors of v with color c
+    conflicts int   // number of edges with the same colors of the ends
+    conflicting []int32 // vertices with conflicts
+    position []int32    // position of the vertex in conflicting, -1 if absent
+    tabu [][]int64  // tabu[v][c] -- iteration until v can't get color c back
+    rng *rand.Rand
+}
+
+func newTabuContext(adj [][]int32, k int32, colors []int32, rng *rand.Rand) *TabuContext {
+    NV := len(adj)
+    t := TabuContext{adj, k, colors, make([][]int32, NV), 0, make([]int32, 0),
+                     make([]int32, NV), make([][]int64, NV), rng}
+    for v := 0; v < NV; v++ {
+        t.gamma[v] = make([]int32, k + 1)
+        t.tabu[v] = make([]int64, k + 1)
+        t.position[v] = -1
+        for _, u := range adj[v] {
+            t.gamma[v][colors[u]]++
+        }
+    }
+    for v := 0; v < NV; v++ {
+        t.conflicts += int(t.gamma[v][colors[v]])
+        t.updateConflicting(int32(v))
+    }
+    t.conflicts /= 2
+    return &t
+}
+
+// keep v in the conflicting vertices iff it has conflicts
+func (t *TabuContext) updateConflicting(v int32) {
+    inConflict := t.gamma[v][t.colors[v]] > 0
+    if inConflict && t.position[v] == -1 {
+        t.position[v] = int32(len(t.conflicting))
+        t.conflicting = append(t.conflicting, v)
+    } else if !inConflict && t.position[v] != -1 {
+        last := t.conflicting[len(t.conflicting)-1]
+        t.conflicting[t.position[v]] = last
+        t.position[last] = t.position[v]
+        t.conflicting = t.conflicting[:len(t.conflicting)-1]
+        t.position[v] = -1
+    }
+}
+
+func (t *TabuContext) move(v int32, color int32) {
+    old := t.colors[v]
+    t.conflicts += int(t.gamma[v][color] - t.gamma[v][old])
+    t.colors[v] = color
+    for _, u := range t.adj[v] {
+        t.gamma[u][old]--
+        t.gamma[u][color]++
+        t.updateConflicting(u)
+    }
+    t.updateConflicting(v)
+}
+
+// best non-tabu move of a conflicting vertex, ties are broken randomly; a
+// tabu move is allowed if it gives less conflicts than best (aspiration)
+func (t *TabuContext) bestMove(iteration int64, best int) (int32, int32, bool) {
+    var moveVertex, moveColor int32
+    bestDelta := int32(0)
+    ties := 0
+    for _, v := range t.conflicting {
+        current := t.gamma[v][t.colors[v]]
+        for color := int32(1); color <= t.k; color++ {
+            if color == t.colors[v] {
+                continue
+            }
+            delta := t.gamma[v][color] - current
+            if t.tabu[v][color] > iteration && t.conflicts + int(delta) >= best {
+                continue
+            }
+            if ties == 0 || delta < bestDelta {
+                bestDelta = delta
+                moveVertex, moveColor = v, color
+                ties = 1
+            } else if delta == bestDelta {
+                ties++
+                if t.rng.Intn(ties) == 0 {
+                    moveVertex, moveColor = v, color
+                }
+            }
+        }
+    }
+    return moveVertex, moveColor, ties > 0
+}
+
+// run until a coloring without conflicts is found, the deadline (zero --
+// no limit) or maxStall iterations without improvement; colors are left
+// with the least conflicts found (conflict tables are not), returns the
+// number of conflicts
+func (t *TabuContext) search(deadline time.Time, maxStall int64) int {
+    best := t.conflicts
+    bestColors := make([]int32, len(t.colors))
+    copy(bestColors, t.colors)
+
+    var iteration, improved int64
+    for ; t.conflicts > 0 && t.k > 1; iteration++ {
+        if iteration - improved > maxStall {
+            break
+        }
+        if !deadline.IsZero() && iteration % 1024 == 0 && time.Now().After(deadline) {
+            break
+        }
+
+        v, color, ok := t.bestMove(iteration, best)
+        if !ok {
+            // all moves are tabu
+            v = t.conflicting[t.rng.Intn(len(t.conflicting))]
+            color = int32(t.rng.Intn(int(t.k - 1))) + 1
+            if color >= t.colors[v] {
+                color++
+            }
+        }
+        old := t.colors[v]
+        t.move(v, color)
+        t.tabu[v][old] = iteration + int64(t.rng.Intn(TABU_TENURE_RANDOM)) +
+                         int64(TABU_TENURE_FACTOR * float64(len(t.conflicting)))
+
+        if t.conflicts < best {
+            best = t.conflicts
+            copy(bestColors, t.colors)
+            improved = iteration
+        }
+    }
+
+    if t.conflicts > best {
+        copy(t.colors, bestColors)
+    }
+    return best
+}
+
+// current colors of the graph with colors above k (or no color) replaced by
+// the colors with the least conflicts with the neighbors colored so far
+func (g *Graph) colorsWithin(adj [][]int32, k int32) []int32 {
+    colors := g.saveColors()
+    for v := 0; v < g.NV(); v++ {
+        if colors[v] > k {
+            colors[v] = 0
+        }
+    }
+    count := make([]int32, k + 1)
+    for v := 0; v < g.NV(); v++ {
+        if colors[v] != 0 {
+            continue
+        }
+        for c := range count {
+            count[c] = 0
+        }
+        for _, u := range adj[v] {
+            count[colors[u]]++
+        }
+        colors[v] = 1
+        for c := int32(2); c <= k; c++ {
+            if count[c] < count[colors[v]] {
+                colors[v] = c
+            }
+        }
+    }
+    return colors
+}
+
+// Tabucol search for a coloring with k colors within the time budget
+// (0 -- no limit) starting from the current colors of the graph. The
+// coloring is left in the graph if it is found, local search never proves
+// that it doesn't exist, so the result is the same as of colorCSP
+func (g *Graph) colorTabu(k int32, budget time.Duration, rng *rand.Rand) (bool, bool) {
+    if k < 1 {
+        return g.NV() == 0, g.NV() != 0
+    }
+    var deadline time.Time
+    if budget > 0 {
+        deadline = time.Now().Add(budget)
+    }
+
+    adj := g.neighbors()
+    t := newTabuContext(adj, k, g.colorsWithin(adj, k), rng)
+    if t.search(deadline, TABU_MAX_STALL) > 0 {
+        return false, true
+    }
+    g.restoreColors(t.colors)
+    return true, false
+}
+
+func (g *Graph) solveTabu(budget time.Duration, seed int64) {
+    rng := rand.New(rand.NewSource(seed))
+    optimal := g.minimizeColorsWith(budget, func(k int32, budget time.Duration) (bool, bool) {
+        return g.colorTabu(k, budget, rng)
+    })
+    g.printSolution(optimal)
+}
+
+//
+// Hybrid evolutionary algorithm
+//
+
+const (
+    HEA_TABU_STALL = 100000 // tabu iterations without improvement for each child
+    HEA_MAX_STALL = 1000    // generations without improvement before giving up
+    HEA_MIN_POPULATION = 2  // GPX needs two parents
+)
+
+// k-coloring of the population
+type Individual struct {
+    colors []int32
+    conflicts int
+}
+
+// greedy partition crossover (GPX): color classes are taken from the
+// parents in turn, each time the largest one of the vertices not colored
+// yet; the vertices left get random colors
+func gpx(a []int32, b []int32, k int32, rng *rand.Rand) []int32 {
+    child := make([]int32, len(a))
+    size := make([]int32, k + 1)
+    parents := [2][]int32{a, b}
+    first := rng.Intn(2)
+    for color := int32(1); color <= k; color++ {
+        parent := parents[(first + int(color)) % 2]
+        for c := range size {
+            size[c] = 0
+        }
+        for v, c := range parent {
+            if child[v] == 0 {
+                size[c]++
+            }
+        }
+        var largest int32 = 1
+        ties := 0
+        for c := int32(1); c <= k; c++ {
+            if size[c] > size[largest] {
+                largest = c
+                ties = 1
+            } else if size[c] == size[largest] {
+                ties++
+                if rng.Intn(ties) == 0 {
+                    largest = c
+                }
+            }
+        }
+        for v, c := range parent {
+            if child[v] == 0 && c == largest {
+                child[v] = color
+            }
+        }
+    }
+    for v := range child {
+        if child[v] == 0 {
+            child[v] = int32(rng.Intn(int(k))) + 1
+        }
+    }
+    return child
+}
+
+// improve the coloring by tabu search
+func improve(adj [][]int32, k int32, colors []int32, deadline time.Time,
+             rng *rand.Rand) Individual {
+    t := newTabuContext(adj, k, colors, rng)
+    return Individual{colors, t.search(deadline, HEA_TABU_STALL)}
+}
+
+// hybrid evolutionary algorithm (Galinier and Hao): the population of
+// k-colorings improved by tabu search, on each generation the child of two
+// random parents by GPX replaces the worse parent after the tabu search. The
+// first individual starts from the current colors of the graph, the others
+// from random colors. Runs within the time budget (0 -- until HEA_MAX_STALL
+// generations without improvement), the result is the same as of colorTabu;
+// population is at least HEA_MIN_POPULATION
+func (g *Graph) colorEvolutionary(k int32, budget time.Duration, population int,
+                                  rng *rand.Rand) (bool, bool) {
+    if k < 1 {
+        return g.NV() == 0, g.NV() != 0
+    }
+    var deadline time.Time
+    if budget > 0 {
+        deadline = time.Now().Add(budget)
+    }
+    expired := func() bool {
+        return !deadline.IsZero() && time.Now().After(deadline)
+    }
+
+    adj := g.neighbors()
+    individuals := make([]Individual, 0, population)
+    best := 0
+    for i := 0; i < population && !expired(); i++ {
+        colors := g.colorsWithin(adj, k)
+        if i > 0 {
+            for v := range colors {
+                colors[v] = int32(rng.Intn(int(k))) + 1
+            }
+        }
+        individuals = append(individuals, improve(adj, k, colors, deadline, rng))
+        if individuals[i].conflicts < individuals[best].conflicts {
+            best = i
+        }
+        if individuals[best].conflicts == 0 {
+            break
+        }
+    }
+
+    if len(individuals) == 0 {
+        return false, true
+    }
+
+    var generation, improved int64
+    for ; individuals[best].conflicts > 0 && len(individuals) > 1; generation++ {
+        if generation - improved > HEA_MAX_STALL || expired() {
+            break
+        }
+
+        a := rng.Intn(len(individuals))
+        b := rng.Intn(len(individuals) - 1)
+        if b >= a {
+            b++
+        }
+        child := improve(adj, k, gpx(individuals[a].colors, individuals[b].colors, k, rng),
+                         deadline, rng)
+
+        worse := a
+        if individuals[b].conflicts > individuals[a].conflicts {
+            worse = b
+        }
+        if child.conflicts < individuals[best].conflicts {
+            improved = generation
+            log.Println("generation", generation, "conflicts", child.conflicts)
+        }
+        individuals[worse] = child
+        // the best one may be replaced by the worse child
+        best = 0
+        for i := range individuals {
+            if individuals[i].conflicts < individuals[best].conflicts {
+                best = i
+            }
+        }
+    }
+
+    if individuals[best].conflicts > 0 {
+        return false, true
+    }
+    g.restoreColors(individuals[best].colors)
+    return true, false
+}
+
+func (g *Graph) solveEvolutionary(budget time.Duration, seed int64, population int) {
+    rng := rand.New(rand.NewSource(seed))
+    optimal := g.minimizeColorsWith(budget, func(k int32, budget time.Duration) (bool, bool) {
+        return g.colorEvolutionary(k, budget, population, rng)
+    })
+    g.printSolution(optimal)
+}
+
+//
+// Clique
+//
+
+// adjacency matrix
+func (g *Graph) adjacency() [][]bool {
+    adj := make([][]bool, g.NV())
+    for i := 0; i < g.NV(); i++ {
+        adj[i] = make([]bool, g.NV())
+    }
+    for _, e := range g.E {
+        adj[e.u][e.v] = true
+        adj[e.v][e.u] = true
+    }
+    return adj
+}
+
+const (
+    // max time budget of the exact clique search for the lower bound
+    CLIQUE_MAX_BUDGET = time.Second
+)
+
+// state of the max clique search
+type CliqueContext struct {
+    adj [][]bool
+    clique []int32 // current clique
+    best []int32   // largest clique found
+    deadline time.Time // stop the search at this time (zero -- no limit)
+    nodes int64
+    stopped bool
+}
+
+// greedy clique: vertices in the order of decreasing degree are added if
+// they are connected to all the clique vertices, starting from each vertex
+func (g *Graph) greedyClique(adj [][]bool) []int32 {
+    NV := g.NV()
+    ord := make([]int32, NV)
+    for i := 0; i < NV; i++ {
+        ord[i] = int32(i)
+    }
+    vertexOrder := VertexOrder{g, ord}
+    sort.Sort(sort.Reverse(ByDegree(vertexOrder)))
+
+    best := make([]int32, 0)
+    for _, start := range vertexOrder.order {
+        // a clique including start is not larger than its degree + 1
+        if len(g.V[start].E) + 1 <= len(best) {
+            break
+        }
+        clique := []int32{start}
+        for _, v := range vertexOrder.order {
+            connected := v != start
+            for _, u := range clique {
+                if !adj[v][u] {
+                    connected = false
+                    break
+                }
+            }
+            if connected {
+                clique = append(clique, v)
+            }
+        }
+        if len(clique) > len(best) {
+            best = clique
+        }
+    }
+    return best
+}
+
+// color candidates greedily (no two adjacent vertices in a color class);
+// the vertices are reordered by colors, colors[i] -- color of candidates[i],
+// the clique of candidates can't be larger than the number of colors
+func (c *CliqueContext) colorCandidates(candidates []int32) []int32 {
+    classes := make([][]int32, 0)
+    for _, v := range candidates {
+        k := 0
+        for ; k < len(classes); k++ {
+            conflict := false
+            for _, u := range classes[k] {
+                if c.adj[v][u] {
+                    conflict = true
+                    break
+                }
+            }
+            if !conflict {
+                break
+            }
+        }
+        if k == len(classes) {
+            classes = append(classes, make([]int32, 0))
+        }
+        classes[k] = append(classes[k], v)
+    }
+
+    colors := make([]int32, 0, len(candidates))
+    candidates = candidates[:0]
+    for k, class := range classes {
+        for _, v := range class {
+            candidates = append(candidates, v)
+            colors = append(colors, int32(k + 1))
+        }
+    }
+    return colors
+}
+
+// branch and bound over candidates connected to all vertices of the current
+// clique, bounded by the number of colors of candidates (Tomita's MCQ)
+func (c *CliqueContext) expand(candidates []int32) {
+    c.nodes++
+    if !c.deadline.IsZero() && c.nodes % 1024 == 0 && time.Now().After(c.deadline) {
+        c.stopped = true
+    }
+    if c.stopped {
+        return
+    }
+
+    colors := c.colorCandidates(candidates)
+    // vertices with the most colors first, the bound only decreases
+    for i := len(candidates) - 1; i >= 0; i-- {
+        if len(c.clique) + int(colors[i]) <= len(c.best) || c.stopped {
+            return
+        }
+        v := candidates[i]
+        c.clique = append(c.clique, v)
+
+        next := make([]int32, 0, i)
+        for _, u := range candidates[:i] {
+            if c.adj[v][u] {
+                next = append(next, u)
+            }
+        }
+        if len(next) == 0 {
+            if len(c.clique) > len(c.best) {
+                c.best = append(c.best[:0], c.clique...)
+            }
+        } else {
+            c.expand(next)
+        }
+
+        c.clique = c.clique[:len(c.clique)-1]
+    }
+}
+
+// largest clique found within the time budget (0 -- no limit) and if it
+// is proven to be the maximum one
+func (g *Graph) maxClique(budget time.Duration) ([]int32, bool) {
+    adj := g.adjacency()
+    c := CliqueContext{adj, make([]int32, 0), g.greedyClique(adj),
+                       time.Time{}, 0, false}
+    if budget > 0 {
+        c.deadline = time.Now().Add(budget)
+    }
+
+    candidates := make([]int32, g.NV())
+    for i := 0; i < g.NV(); i++ {
+        candidates[i] = int32(i)
+    }
+    c.expand(candidates)
+    return c.best, !c.stopped
 }
 
 //
@@ -331,7 +1080,6 @@ func (c *CSPContext) getMRVVertex() int32 {
     // there must be unset vertices
     if c.currentUnassignedVertex >= c.g.NV() {
         panic("Call to getMRVVertex with no unset variables")
-        return -1
     }
 
     var vertex int32 = -1
@@ -356,7 +1104,6 @@ func (c *CSPContext) getMRVVertex() int32 {
 
     if vertex == -1 {
         panic("getMRVVertex: Could not find the vertex")
-        return -1
     }
     return vertex;
 }
@@ -365,7 +1112,6 @@ func (c *CSPContext) getMRVVertex() int32 {
 func (c *CSPContext) getLCVColor(vertex int32) int32 {
     if len(c.domains[vertex]) == 0 {
         panic(fmt.Sprintf("getLCVColor: No colors for vertex %d\n", vertex))
-        return -1
     }
 
     var lcvColor int32 = int32(-1)
@@ -404,6 +1150,16 @@ func (c *CSPContext) getLCVColorOrder(vertex int32) []LCVColorPair {
 }
 
 func (c *CSPContext) solve(indent int) bool {
+    // time.Now() is cheap compared to copying all the domains in each node,
+    // so the deadline is checked on every node
+    c.nodes++
+    if !c.deadline.IsZero() && time.Now().After(c.deadline) {
+        c.stopped = true
+    }
+    if c.stopped {
+        return false
+    }
+
     // all vars assigned?
     if c.currentUnassignedVertex >= c.g.NV() {
         return c.g.valid()
@@ -417,7 +1173,7 @@ func (c *CSPContext) solve(indent int) bool {
     // 4.+try LCV (for values)
     // 5.+try constraint propagation (stronger version of forward checking)
     //    AC3
-    // 6. local search (min conflicts)
+    // 6.+local search (min conflicts), see colorTabu
     // 7. backjumping (conflict-directed)
     // 8. constraint learning (?)
 
@@ -456,6 +1212,11 @@ func (c *CSPContext) solve(indent int) bool {
                 return true
             }
 
+            // stopped search unwinds without restoring the domains, each
+            // restore copies all of them
+            if c.stopped {
+                break
+            }
             // restore domains state to previous
             popDomains(&c.domains, &savedDomains)
         }
@@ -464,14 +1225,17 @@ func (c *CSPContext) solve(indent int) bool {
         //fmt.Println(lcvPairs)
         //return false
 
-        for color, _ := range lcvPairs {
-            //color, _ := c.domains[vertex][order[i][0]]
+        for _, pair := range lcvPairs {
+            color := pair[0]
 
-            c.g.V[vertex].color = int32(color)
-            c.forwardCheckVertexColor(int32(vertex), int32(color))
+            c.g.V[vertex].color = color
+            c.forwardCheckVertexColor(int32(vertex), color)
             if c.solve(indent + 1) {
                 return true
             }
+            if c.stopped {
+                break
+            }
             popDomains(&c.domains, &savedDomains)
         }
     }
@@ -486,46 +1250,156 @@ func (c *CSPContext) solve(indent int) bool {
 // contraint-satisfaction approach
 func (g *Graph) solveCSP(nColors int32) int {
     //fmt.Println("Solving for", nColors, "colors")
+    if found, _ := g.colorCSP(nColors, 0); found {
+        g.printSolution(g.chromaticNumber() <= g.greedyLowerBound())
+        return 0
+    }
+    return 1
+}
 
+// try to color the graph with nColors colors within the time budget
+// (0 -- no limit); returns if the coloring is found and if the search
+// was stopped by the time budget, i.e. it is not known if it exists
+func (g *Graph) colorCSP(nColors int32, budget time.Duration) (bool, bool) {
     //csp := CSPContext{g, nil, nColors, 0, VAR_BRUTE, VAL_BRUTE}
-    csp := CSPContext{g, nil, nColors, 0, VAR_BRUTE, VAL_LCV}
+    csp := CSPContext{g, nil, nColors, 0, VAR_BRUTE, VAL_LCV, time.Time{}, 0, false}
+    if budget > 0 {
+        csp.deadline = time.Now().Add(budget)
+    }
+    g.resetColors()
     csp.init(int(nColors))
-    if csp.solve(0) {
-        g.printSolution()
-        return 0
+    found := csp.solve(0)
+    return found, csp.stopped
+}
+
+// the clique search is only a part of the work, it gets the time budget
+// up to CLIQUE_MAX_BUDGET
+func cliqueBudget(budget time.Duration) time.Duration {
+    if budget == 0 || budget > CLIQUE_MAX_BUDGET {
+        return CLIQUE_MAX_BUDGET
     }
-    return 1
+    return budget
 }
 
-func solveFile(filename string, alg string, nColors int32) int {
-    file, err := os.Open(filename)
-    if err != nil {
-        fmt.Println("Cannot open file:", filename, err)
-        return 2
+// lower bound of the number of colors: all vertices of a clique have
+// different colors, so it is the size of the largest clique found within
+// the clique budget
+func (g *Graph) lowerBound(budget time.Duration) int32 {
+    clique, exact := g.maxClique(cliqueBudget(budget))
+    log.Println("clique of", len(clique), "vertices, max", exact)
+    return int32(len(clique))
+}
+
+// cheap lower bound by the greedy clique for the algs which don't search
+// for the optimum
+func (g *Graph) greedyLowerBound() int32 {
+    return int32(len(g.greedyClique(g.adjacency())))
+}
+
+// coloring attempt with k colors within the time budget, returns if the
+// coloring is found (and left in the graph) and if the attempt was stopped
+// by the budget, i.e. it is not known if the coloring exists
+type ColoringAttempt func(k int32, budget time.Duration) (bool, bool)
+
+// minimizeColors with CSP attempts
+func (g *Graph) minimizeColors(budget time.Duration) bool {
+    return g.minimizeColorsWith(budget, g.colorCSP)
+}
+
+// start from the better of greedy and DSATUR colorings and try to color
+// the graph with one color less than the best coloring found until the
+// attempt proves that it is impossible, runs out of the budget or the lower
+// bound is reached. Attempts start from the best coloring left in the
+// graph. The graph is left with the best coloring, returns if it is proven
+// to be optimal
+func (g *Graph) minimizeColorsWith(budget time.Duration, attempt ColoringAttempt) bool {
+    g.colorGreedy()
+    best := g.saveColors()
+    k := g.chromaticNumber()
+    g.colorDSatur()
+    if g.chromaticNumber() < k {
+        best = g.saveColors()
+        k = g.chromaticNumber()
+    }
+    g.restoreColors(best)
+    lower := g.lowerBound(budget)
+    log.Println("initial coloring with", k, "colors, lower bound", lower)
+
+    optimal := k <= lower
+    for !optimal {
+        found, stopped := attempt(k - 1, budget)
+        if found {
+            best = g.saveColors()
+            k = g.chromaticNumber()
+            optimal = k <= lower
+            log.Println("found coloring with", k, "colors")
+        } else if stopped {
+            log.Println("no coloring with", k - 1, "colors found within", budget)
+            break
+        } else {
+            log.Println("no coloring with", k - 1, "colors exists")
+            optimal = true
+        }
     }
-    defer file.Close()
 
-    var NV, NE int32
-    var i, v, u int32
+    g.restoreColors(best)
+    return optimal
+}
 
-    fmt.Fscanf(file, "%d %d", &NV, &NE)
+func (g *Graph) solveMinimize(budget time.Duration) {
+    optimal := g.minimizeColors(budget)
+    g.printSolution(optimal)
+}
 
-    //v := make([]int32, n)
-    E := make([]Edge, NE)
+// graph of NV vertices with edges E
+func newGraph(NV int32, E []Edge) *Graph {
     V := make([]Vertex, NV)
 
+    var i int32
     for i = 0; i < NV; i++ {
         V[i] = Vertex{int32(i), 0, make([]int32, 0)}
     }
 
+    for i = 0; i < int32(len(E)); i++ {
+        V[E[i].u].E = append(V[E[i].u].E, i)
+        V[E[i].v].E = append(V[E[i].v].E, i)
+    }
+
+    return &Graph{E, V}
+}
+
+func readGraph(file io.Reader) *Graph {
+    var NV, NE int32
+    var i, v, u int32
+
+    fmt.Fscanf(file, "%d %d", &NV, &NE)
+
+    //v := make([]int32, n)
+    E := make([]Edge, NE)
+
     for i = 0; i < NE; i++ {
         fmt.Fscanf(file, "%d %d", &v, &u)
         E[i] = Edge{v, u}
-        V[v].E = append(V[v].E, i)
-        V[u].E = append(V[u].E, i)
     }
 
-    g := Graph{E, V}
+    return newGraph(NV, E)
+}
+
+// budget -- time budget of each CSP, tabu or HEA attempt of minimize, tabu
+// and hea algs and of the DSATUR branch and bound, the clique search gets
+// up to CLIQUE_MAX_BUDGET of it
+// seed -- random seed of tabu search and HEA
+// population -- HEA population size
+func solveFile(filename string, alg string, nColors int32, budget time.Duration,
+               seed int64, population int) int {
+    file, err := os.Open(filename)
+    if err != nil {
+        fmt.Println("Cannot open file:", filename, err)
+        return 2
+    }
+    defer file.Close()
+
+    g := readGraph(file)
 
     if nColors == -1 {
         nColors = g.degree() + 1
@@ -540,6 +1414,16 @@ func solveFile(filename string, alg string, nColors int32) int {
         g.solveGreedySimple()
     case alg == "csp":
         return g.solveCSP(nColors)
+    case alg == "minimize":
+        g.solveMinimize(budget)
+    case alg == "dsatur":
+        g.solveDSatur()
+    case alg == "dsaturbnb":
+        g.solveDSaturBranchAndBound(budget)
+    case alg == "tabu":
+        g.solveTabu(budget, seed)
+    case alg == "hea":
+        g.solveEvolutionary(budget, seed, population)
     default:
         return g.solveCSP(nColors)
     }
@@ -591,15 +1475,27 @@ func test(alg string) {
 }
 
 func main() {
+    budget := flag.Float64("time", 10, "time budget of each CSP, tabu or HEA attempt of minimize, tabu and hea algs and of the DSATUR branch and bound, seconds (0 -- no limit)")
+    seed := flag.Int64("seed", 1, "random seed")
+    population := flag.Int("population", 10, "HEA population size (at least 2)")
+    flag.Parse()
+    if *population < HEA_MIN_POPULATION {
+        fmt.Fprintln(os.Stderr, "population must be at least", HEA_MIN_POPULATION)
+        flag.Usage()
+        os.Exit(2)
+    }
+
+    // solver [flags] file [alg] [ncolors]
     alg := "auto"
     nColors := -1
-    if len(os.Args) > 2 {
-        alg = os.Args[2]
+    if flag.NArg() > 1 {
+        alg = flag.Arg(1)
     }
-    if len(os.Args) > 3 {
-        nColors, _ = strconv.Atoi(os.Args[3])
+    if flag.NArg() > 2 {
+        nColors, _ = strconv.Atoi(flag.Arg(2))
     }
-    os.Exit(solveFile(os.Args[1], alg, int32(nColors)))
+    os.Exit(solveFile(flag.Arg(0), alg, int32(nColors),
+                      time.Duration(*budget * float64(time.Second)), *seed, *population))
     //test(alg)
 
 }
@@ -0,0 +1,343 @@
+package main
+
+import "testing"
+import "time"
+import "os"
+import "math/rand"
+
+// graph of n vertices with edges given by pairs of vertices
+func testGraph(n int32, edges [][2]int32) *Graph {
+    E := make([]Edge, len(edges))
+    for i, e := range edges {
+        E[i] = Edge{e[0], e[1]}
+    }
+    return newGraph(n, E)
+}
+
+// cycle of n vertices
+func cycle(n int32) [][2]int32 {
+    edges := make([][2]int32, n)
+    for i := int32(0); i < n; i++ {
+        edges[i] = [2]int32{i, (i + 1) % n}
+    }
+    return edges
+}
+
+func TestMinimizeColors(t *testing.T) {
+    graphs := []struct {
+        name string
+        g *Graph
+        colors int32
+    }{
+        {"no edges", testGraph(3, nil), 1},
+        {"edge", testGraph(2, [][2]int32{{0, 1}}), 2},
+        {"triangle", testGraph(3, [][2]int32{{0, 1}, {1, 2}, {0, 2}}), 3},
+        {"even cycle", testGraph(6, cycle(6)), 2},
+        {"odd cycle", testGraph(7, cycle(7)), 3},
+        {"K4", testGraph(4, [][2]int32{{0, 1}, {0, 2}, {0, 3}, {1, 2}, {1, 3}, {2, 3}}), 4},
+        // wheel with 5 spokes needs 4 colors, greedy uses more on the crown
+        {"wheel", testGraph(6, append(cycle(5), [2]int32{5, 0}, [2]int32{5, 1},
+                                      [2]int32{5, 2}, [2]int32{5, 3}, [2]int32{5, 4})), 4},
+    }
+
+    for _, test := range graphs {
+        optimal := test.g.minimizeColors(time.Second)
+        if !test.g.valid() {
+            t.Error(test.name, "coloring is not valid")
+        }
+        if !optimal || test.g.chromaticNumber() != test.colors {
+            t.Error(test.name, "colors", test.g.chromaticNumber(), "optimal", optimal,
+                    "!=", test.colors)
+        }
+    }
+}
+
+func readTestGraph(t *testing.T, filename string) *Graph {
+    file, err := os.Open(filename)
+    if err != nil {
+        t.Fatal("Cannot open file", filename, err)
+    }
+    defer file.Close()
+    return readGraph(file)
+}
+
+// the attempt stopped by the budget leaves the best coloring found so far,
+// which is not claimed to be optimal
+func TestMinimizeColorsBudget(t *testing.T) {
+    g := readTestGraph(t, "data/gc_100_5")
+    g.colorGreedy()
+    greedy := g.chromaticNumber()
+
+    g = readTestGraph(t, "data/gc_100_5")
+    if g.minimizeColors(time.Nanosecond) {
+        t.Error("colors", g.chromaticNumber(), "can't be proven optimal so fast")
+    }
+    if !g.valid() || g.chromaticNumber() > greedy {
+        t.Error("colors", g.chromaticNumber(), "greedy", greedy)
+    }
+}
+
+// random graph of n vertices, each edge is present with probability p
+func randomGraph(rng *rand.Rand, n int32, p float64) *Graph {
+    edges := make([][2]int32, 0)
+    for u := int32(0); u < n; u++ {
+        for v := u + 1; v < n; v++ {
+            if rng.Float64() < p {
+                edges = append(edges, [2]int32{u, v})
+            }
+        }
+    }
+    return testGraph(n, edges)
+}
+
+// max clique size by checking all subsets of vertices
+func bruteForceClique(g *Graph) int {
+    adj := g.adjacency()
+    best := 0
+    for set := 0; set < 1 << g.NV(); set++ {
+        size := 0
+        clique := true
+        for u := 0; u < g.NV() && clique; u++ {
+            if set & (1 << u) == 0 {
+                continue
+            }
+            size++
+            for v := u + 1; v < g.NV(); v++ {
+                if set & (1 << v) != 0 && !adj[u][v] {
+                    clique = false
+                    break
+                }
+            }
+        }
+        if clique && size > best {
+            best = size
+        }
+    }
+    return best
+}
+
+// checks the vertices are pairwise adjacent
+func isClique(g *Graph, clique []int32) bool {
+    adj := g.adjacency()
+    for i, u := range clique {
+        for _, v := range clique[i+1:] {
+            if !adj[u][v] {
+                return false
+            }
+        }
+    }
+    return true
+}
+
+func TestMaxClique(t *testing.T) {
+    graphs := []struct {
+        name string
+        g *Graph
+        size int
+    }{
+        {"empty", testGraph(0, nil), 0},
+        {"no edges", testGraph(3, nil), 1},
+        {"odd cycle", testGraph(7, cycle(7)), 2},
+        {"K4", testGraph(4, [][2]int32{{0, 1}, {0, 2}, {0, 3}, {1, 2}, {1, 3}, {2, 3}}), 4},
+        {"wheel", testGraph(6, append(cycle(5), [2]int32{5, 0}, [2]int32{5, 1},
+                                      [2]int32{5, 2}, [2]int32{5, 3}, [2]int32{5, 4})), 3},
+    }
+    rng := rand.New(rand.NewSource(1))
+    for i := 0; i < 30; i++ {
+        g := randomGraph(rng, 12, 0.2 + 0.6 * rng.Float64())
+        graphs = append(graphs, struct {
+            name string
+            g *Graph
+            size int
+        }{"random", g, bruteForceClique(g)})
+    }
+
+    for _, test := range graphs {
+        clique, exact := test.g.maxClique(0)
+        if !exact || len(clique) != test.size || !isClique(test.g, clique) {
+            t.Error(test.name, "clique", clique, "exact", exact, "!=", test.size)
+        }
+    }
+}
+
+// greedy coloring of the complete bipartite graph with a perfect matching
+// removed may use up to n colors, the clique bound proves 2 colors optimal
+// as soon as they are found, without trying 1 color
+func TestMinimizeColorsClique(t *testing.T) {
+    n := int32(5)
+    edges := make([][2]int32, 0)
+    for u := int32(0); u < n; u++ {
+        for v := int32(0); v < n; v++ {
+            if u != v {
+                edges = append(edges, [2]int32{2 * u, 2 * v + 1})
+            }
+        }
+    }
+    g := testGraph(2 * n, edges)
+    if !g.minimizeColors(time.Second) || !g.valid() || g.chromaticNumber() != 2 {
+        t.Error("colors", g.chromaticNumber(), "!= 2")
+    }
+}
+
+// min number of colors found by complete CSP search
+func cspChromaticNumber(g *Graph) int32 {
+    var k int32 = 0
+    for k < int32(g.NV()) {
+        if found, _ := g.colorCSP(k, 0); found {
+            break
+        }
+        k++
+    }
+    return k
+}
+
+func TestDSatur(t *testing.T) {
+    rng := rand.New(rand.NewSource(1))
+    for i := 0; i < 30; i++ {
+        g := randomGraph(rng, 12, 0.2 + 0.6 * rng.Float64())
+        colors := cspChromaticNumber(g)
+
+        g.colorDSatur()
+        if !g.valid() || g.chromaticNumber() < colors {
+            t.Error("DSATUR colors", g.chromaticNumber(), "optimum", colors)
+        }
+
+        optimal := g.dsaturBranchAndBound(0)
+        if !optimal || !g.valid() || g.chromaticNumber() != colors {
+            t.Error("DSATUR B&B colors", g.chromaticNumber(), "optimal", optimal,
+                    "!=", colors)
+        }
+    }
+}
+
+// gc_50_3 needs 6 colors, DSATUR greedy uses more
+func TestDSaturBranchAndBound(t *testing.T) {
+    g := readTestGraph(t, "data/gc_50_3")
+    if !g.dsaturBranchAndBound(10 * time.Second) || !g.valid() || g.chromaticNumber() != 6 {
+        t.Error("colors", g.chromaticNumber(), "!= 6")
+    }
+}
+
+// conflict tables updated by moves match the ones built from scratch
+func TestTabuMoves(t *testing.T) {
+    rng := rand.New(rand.NewSource(1))
+    g := randomGraph(rng, 30, 0.3)
+    adj := g.neighbors()
+    var k int32 = 4
+    colors := make([]int32, g.NV())
+    for v := range colors {
+        colors[v] = int32(rng.Intn(int(k))) + 1
+    }
+
+    tabu := newTabuContext(adj, k, colors, rng)
+    for i := 0; i < 200; i++ {
+        tabu.move(int32(rng.Intn(g.NV())), int32(rng.Intn(int(k))) + 1)
+    }
+
+    fresh := newTabuContext(adj, k, append([]int32(nil), tabu.colors...), rng)
+    if tabu.conflicts != fresh.conflicts || len(tabu.conflicting) != len(fresh.conflicting) {
+        t.Error("conflicts", tabu.conflicts, len(tabu.conflicting),
+                "!=", fresh.conflicts, len(fresh.conflicting))
+    }
+    for v := range adj {
+        for c := int32(0); c <= k; c++ {
+            if tabu.gamma[v][c] != fresh.gamma[v][c] {
+                t.Error("vertex", v, "color", c, "conflicts", tabu.gamma[v][c],
+                        "!=", fresh.gamma[v][c])
+            }
+        }
+        if (tabu.position[v] == -1) != (fresh.position[v] == -1) {
+            t.Error("vertex", v, "conflicting", tabu.position[v], fresh.position[v])
+        }
+    }
+}
+
+func TestColorTabu(t *testing.T) {
+    rng := rand.New(rand.NewSource(1))
+    for i := 0; i < 30; i++ {
+        g := randomGraph(rng, 12, 0.2 + 0.6 * rng.Float64())
+        colors := cspChromaticNumber(g)
+
+        g.colorDSatur()
+        if found, _ := g.colorTabu(colors, time.Second, rng); !found || !g.valid() ||
+           g.chromaticNumber() > colors {
+            t.Error("tabu colors", g.chromaticNumber(), "found", found, "!=", colors)
+        }
+
+        start := g.saveColors()
+        if found, stopped := g.colorTabu(colors - 1, 100 * time.Millisecond, rng); found || !stopped {
+            t.Error("tabu found coloring with", colors - 1, "colors")
+        }
+        for v, c := range g.saveColors() {
+            if c != start[v] {
+                t.Error("failed attempt changed color of vertex", v)
+            }
+        }
+    }
+}
+
+// tabu attempts reach the optimal 6 colors of gc_50_3
+func TestMinimizeColorsTabu(t *testing.T) {
+    g := readTestGraph(t, "data/gc_50_3")
+    rng := rand.New(rand.NewSource(1))
+    g.minimizeColorsWith(time.Second, func(k int32, budget time.Duration) (bool, bool) {
+        return g.colorTabu(k, budget, rng)
+    })
+    if !g.valid() || g.chromaticNumber() != 6 {
+        t.Error("colors", g.chromaticNumber(), "!= 6")
+    }
+}
+
+// the child of a coloring and itself has the same color classes
+func TestGPX(t *testing.T) {
+    rng := rand.New(rand.NewSource(1))
+    for i := 0; i < 30; i++ {
+        g := randomGraph(rng, 12, 0.2 + 0.6 * rng.Float64())
+        g.colorDSatur()
+        k := g.chromaticNumber()
+        parent := g.saveColors()
+
+        child := gpx(parent, parent, k, rng)
+        for u := range child {
+            if child[u] < 1 || child[u] > k {
+                t.Error("vertex", u, "color", child[u], "out of", k)
+            }
+            for v := range child {
+                if (child[u] == child[v]) != (parent[u] == parent[v]) {
+                    t.Error("vertices", u, v, "colors", child[u], child[v],
+                            "parent colors", parent[u], parent[v])
+                }
+            }
+        }
+    }
+}
+
+func TestColorEvolutionary(t *testing.T) {
+    rng := rand.New(rand.NewSource(1))
+    for i := 0; i < 30; i++ {
+        g := randomGraph(rng, 12, 0.2 + 0.6 * rng.Float64())
+        colors := cspChromaticNumber(g)
+
+        g.colorDSatur()
+        if found, _ := g.colorEvolutionary(colors, time.Second, 4, rng); !found ||
+           !g.valid() || g.chromaticNumber() > colors {
+            t.Error("HEA colors", g.chromaticNumber(), "found", found, "!=", colors)
+        }
+        if found, stopped := g.colorEvolutionary(colors - 1, 10 * time.Millisecond, 4, rng);
+           found || !stopped {
+            t.Error("HEA found coloring with", colors - 1, "colors")
+        }
+    }
+}
+
+// HEA attempts reach the optimal 6 colors of gc_50_3
+func TestMinimizeColorsEvolutionary(t *testing.T) {
+    g := readTestGraph(t, "data/gc_50_3")
+    rng := rand.New(rand.NewSource(1))
+    g.minimizeColorsWith(time.Second, func(k int32, budget time.Duration) (bool, bool) {
+        return g.colorEvolutionary(k, budget, 10, rng)
+    })
+    if !g.valid() || g.chromaticNumber() != 6 {
+        t.Error("colors", g.chromaticNumber(), "!= 6")
+    }
+}